@@ -2,13 +2,18 @@ package bibtex
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"sort"
 	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
 )
 
 // BibString is a segment of a bib string.
@@ -21,6 +26,8 @@ type BibString interface {
 type BibVar struct {
 	Key   string    // Variable key.
 	Value BibString // Variable actual value.
+
+	undefined bool // Not defined where referenced, see UnresolvedStrings.
 }
 
 // RawString is the internal representation of the variable.
@@ -79,40 +86,184 @@ func (c *BibComposite) RawString() string {
 		if i > 0 {
 			buf.WriteString(" # ")
 		}
-		switch comp := comp.(type) {
-		case *BibConst:
-			buf.WriteString(comp.RawString())
+		buf.WriteString(comp.RawString())
+	}
+	return buf.String()
+}
+
+// Resolve returns the composite string with its string variables expanded
+// from vars instead of the values they had when parsed. Variables missing from
+// vars keep their parsed value. The literal operands are used as they are.
+func (c *BibComposite) Resolve(vars map[string]string) string {
+	var buf bytes.Buffer
+	for _, s := range *c {
+		switch s := s.(type) {
 		case *BibVar:
-			buf.WriteString(comp.RawString())
+			if val, ok := vars[s.Key]; ok {
+				buf.WriteString(val)
+				continue
+			}
 		case *BibComposite:
-			buf.WriteString(comp.RawString())
+			buf.WriteString(s.Resolve(vars))
+			continue
 		}
+		buf.WriteString(s.String())
 	}
 	return buf.String()
 }
 
+// concat joins s to the end of a (possibly composite) string l.
+func concat(l BibString, s BibString) BibString {
+	if comp, ok := l.(*BibComposite); ok {
+		return comp.Append(s)
+	}
+	return NewBibComposite(l).Append(s)
+}
+
+// DelimiterKind is how a field value is written in the BibTeX source.
+type DelimiterKind int
+
+const (
+	// DelimBraces is a value delimited by braces, e.g. {value}.
+	DelimBraces DelimiterKind = iota
+	// DelimQuotes is a value delimited by double quotes, e.g. "value".
+	DelimQuotes
+	// DelimBare is an undelimited (numeric) value, e.g. 2020.
+	DelimBare
+	// DelimMacro is a reference to a string variable, e.g. jan.
+	DelimMacro
+	// DelimConcat is a concatenation of values, e.g. "a" # b.
+	DelimConcat
+)
+
+func (k DelimiterKind) String() string {
+	switch k {
+	case DelimBraces:
+		return "braces"
+	case DelimQuotes:
+		return "quotes"
+	case DelimBare:
+		return "bare"
+	case DelimMacro:
+		return "macro"
+	case DelimConcat:
+		return "concat"
+	}
+	return fmt.Sprintf("DelimiterKind(%d)", int(k))
+}
+
 // BibEntry is a record of BibTeX record.
 type BibEntry struct {
 	Type     string
 	CiteName string
 	Fields   map[string]BibString
+	Source   string // File the entry was parsed from, if known.
+	Raw      string // Source text of the entry, see Parser.KeepRawSource.
+	Line     int    // Line the entry starts on, see Parser.TrackPositions.
+
+	delims   map[string]DelimiterKind // Delimiters of parsed fields.
+	order    []string                 // Field names in the order added.
+	verbatim map[string]bool          // Folded Parser.VerbatimFields, if set.
 }
 
+var spaceStripper = strings.NewReplacer(" ", "")
+
 // NewBibEntry creates a new BibTeX entry.
 func NewBibEntry(entryType string, citeName string) *BibEntry {
-	spaceStripper := strings.NewReplacer(" ", "")
-	cleanedType := strings.ToLower(spaceStripper.Replace(entryType))
+	cleanedType := foldKey(spaceStripper.Replace(entryType))
 	cleanedName := spaceStripper.Replace(citeName)
 	return &BibEntry{
 		Type:     cleanedType,
 		CiteName: cleanedName,
 		Fields:   map[string]BibString{},
+		delims:   map[string]DelimiterKind{},
+	}
+}
+
+// Delimiter returns how the value of the named field was delimited in the
+// BibTeX source. For fields that were not parsed (e.g. added with AddField)
+// this is inferred from the type of the value.
+func (entry *BibEntry) Delimiter(name string) DelimiterKind {
+	if d, ok := entry.delims[name]; ok {
+		return d
 	}
+	switch val := entry.Fields[name].(type) {
+	case *BibVar:
+		return DelimMacro
+	case *BibComposite:
+		return DelimConcat
+	case BibConst:
+		if _, err := strconv.Atoi(val.String()); err == nil {
+			return DelimBare
+		}
+	}
+	return DelimBraces
 }
 
 // AddField adds a field (key-value) to a BibTeX entry.
 func (entry *BibEntry) AddField(name string, value BibString) {
-	entry.Fields[strings.TrimSpace(name)] = value
+	name = strings.TrimSpace(name)
+	if _, exists := entry.Fields[name]; !exists {
+		entry.order = append(entry.order, name)
+	}
+	entry.Fields[name] = value
+	delete(entry.delims, name)
+}
+
+// fieldName returns the name the named field of entry is stored under,
+// compared case-insensitively as by foldKey. An exact match is preferred,
+// otherwise the first match in fieldNames order is returned.
+func (entry *BibEntry) fieldName(name string) (string, bool) {
+	if _, ok := entry.Fields[name]; ok {
+		return name, true
+	}
+	for _, stored := range entry.fieldNames() {
+		if foldKey(stored) == foldKey(name) {
+			return stored, true
+		}
+	}
+	return "", false
+}
+
+// field returns the value of the named field of entry, see fieldName.
+func (entry *BibEntry) field(name string) (BibString, bool) {
+	stored, ok := entry.fieldName(name)
+	if !ok {
+		return nil, false
+	}
+	return entry.Fields[stored], true
+}
+
+// fieldNames returns the names of the fields of entry in the order they were
+// added (i.e. source order for parsed entries). Fields set in the Fields map
+// directly follow in sorted order.
+func (entry *BibEntry) fieldNames() []string {
+	names := make([]string, 0, len(entry.Fields))
+	listed := make(map[string]bool, len(entry.order))
+	for _, name := range entry.order {
+		if _, ok := entry.Fields[name]; ok && !listed[name] {
+			listed[name] = true
+			names = append(names, name)
+		}
+	}
+	var rest []string
+	for name := range entry.Fields {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// sortedFieldNames returns the names of the fields of entry in sorted order.
+func (entry *BibEntry) sortedFieldNames() []string {
+	names := make([]string, 0, len(entry.Fields))
+	for name := range entry.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // String returns a BibTex entry as a simplified BibTex string.
@@ -136,7 +287,9 @@ func (entry *BibEntry) RawString() string {
 	var bibtex bytes.Buffer
 	bibtex.WriteString(fmt.Sprintf("@%s{%s,\n", entry.Type, entry.CiteName))
 	for key, val := range entry.Fields {
-		if i, err := strconv.Atoi(strings.TrimSpace(val.String())); err == nil {
+		if _, isConst := val.(BibConst); !isConst {
+			bibtex.WriteString(fmt.Sprintf("  %s = %s,\n", key, val.RawString()))
+		} else if i, err := strconv.Atoi(strings.TrimSpace(val.String())); err == nil {
 			bibtex.WriteString(fmt.Sprintf("  %s = %d,\n", key, i))
 		} else {
 			bibtex.WriteString(fmt.Sprintf("  %s = %s,\n", key, val.RawString()))
@@ -147,6 +300,54 @@ func (entry *BibEntry) RawString() string {
 	return bibtex.String()
 }
 
+// Fingerprint returns a stable hash of a BibTeX entry, computed from its type,
+// cite name and the (displayed) values of its fields. It does not depend on the
+// order of the fields, how the values were delimited in the source, or whether
+// accents are written as LaTeX or Unicode: values are compared with LaTeX
+// decoded and protective braces removed, e.g. {M\"uller} is Müller, except
+// in verbatim fields such as url.
+func (entry *BibEntry) Fingerprint() string {
+	fields := make(map[string]string, len(entry.Fields))
+	keys := make([]string, 0, len(entry.Fields))
+	for key, val := range entry.Fields {
+		key = foldKey(key)
+		fields[key] = strings.TrimSpace(entry.decodeField(key, val.String()))
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	write := func(s string) { fmt.Fprintf(h, "%d:%s;", len(s), s) }
+	write(foldKey(entry.Type))
+	write(entry.CiteName)
+	for _, key := range keys {
+		write(key)
+		write(fields[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Equal returns true if entry and other have the same type and cite name
+// (case-insensitively) and the same fields with the same (displayed) values.
+// Unlike Fingerprint, values must match exactly, but the order of the fields,
+// the case of their names and how the values were delimited do not matter.
+func (entry *BibEntry) Equal(other *BibEntry) bool {
+	if foldKey(entry.Type) != foldKey(other.Type) || foldKey(entry.CiteName) != foldKey(other.CiteName) ||
+		len(entry.Fields) != len(other.Fields) {
+		return false
+	}
+	values := make(map[string]string, len(other.Fields))
+	for key, val := range other.Fields {
+		values[foldKey(key)] = val.String()
+	}
+	for key, val := range entry.Fields {
+		if v, ok := values[foldKey(key)]; !ok || v != val.String() {
+			return false
+		}
+	}
+	return true
+}
+
 // BibTex is a list of BibTeX entries.
 type BibTex struct {
 	Preambles []BibString        // List of Preambles
@@ -156,6 +357,12 @@ type BibTex struct {
 	// A list of default BibVars that are implicitly
 	// defined and can be used without defining
 	defaultVars map[string]string
+
+	stringOrder []string // Keys of StringVar in order of definition.
+
+	// caseSensitive makes string var names case-sensitive, see
+	// Parser.CaseSensitiveMacros.
+	caseSensitive bool
 }
 
 // NewBibTex creates a new BibTex data structure.
@@ -193,17 +400,315 @@ func (bib *BibTex) AddEntry(entry *BibEntry) {
 	bib.Entries = append(bib.Entries, entry)
 }
 
+// EntriesByType groups the entries of bib by their (case-folded) type, each
+// group keeping the order of the entries in bib. @modify entries are left
+// out, see ApplyModifies.
+func (bib *BibTex) EntriesByType() map[string][]*BibEntry {
+	groups := make(map[string][]*BibEntry)
+	for _, entry := range bib.Entries {
+		if isModify(entry) {
+			continue
+		}
+		entryType := foldKey(entry.Type)
+		groups[entryType] = append(groups[entryType], entry)
+	}
+	return groups
+}
+
+// DominantType returns the most common (case-folded) entry type of bib, or ""
+// if bib has no entries. Ties go to the type seen first. @modify entries are
+// not counted.
+func (bib *BibTex) DominantType() string {
+	counts := make(map[string]int)
+	var types []string // In order of first appearance.
+	for _, entry := range bib.Entries {
+		if isModify(entry) {
+			continue
+		}
+		entryType := foldKey(entry.Type)
+		if counts[entryType] == 0 {
+			types = append(types, entryType)
+		}
+		counts[entryType]++
+	}
+	var dominant string
+	for _, entryType := range types {
+		if counts[entryType] > counts[dominant] {
+			dominant = entryType
+		}
+	}
+	return dominant
+}
+
+// UniquePrefixes maps the cite name of each entry of bib to its shortest
+// prefix that no other cite name starts with, compared case-insensitively,
+// e.g. for prefix matching in a picker. A cite name that is a prefix of
+// another, or a duplicate, maps to itself.
+func (bib *BibTex) UniquePrefixes() map[string]string {
+	names := make([][]rune, len(bib.Entries))
+	lower := make([][]rune, len(bib.Entries))
+	order := make([]int, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		names[i] = []rune(entry.CiteName)
+		lower[i] = make([]rune, len(names[i]))
+		for j, r := range names[i] {
+			lower[i][j] = unicode.ToLower(r)
+		}
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return string(lower[order[i]]) < string(lower[order[j]]) })
+
+	// Sorted, the names sharing the longest prefix with a name are next to it.
+	prefixes := make(map[string]string, len(bib.Entries))
+	for i, idx := range order {
+		n := 0
+		if i > 0 {
+			n = commonPrefix(lower[order[i-1]], lower[idx])
+		}
+		if i+1 < len(order) {
+			if next := commonPrefix(lower[idx], lower[order[i+1]]); next > n {
+				n = next
+			}
+		}
+		if n < len(names[idx]) {
+			n++
+		}
+		prefixes[bib.Entries[idx].CiteName] = string(names[idx][:n])
+	}
+	return prefixes
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b.
+func commonPrefix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// FieldCoverage returns the number of entries that have a non-empty value for
+// each field name, case-folded.
+func (bib *BibTex) FieldCoverage() map[string]int {
+	coverage := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, entry := range bib.Entries {
+		for name := range seen {
+			delete(seen, name)
+		}
+		for name, val := range entry.Fields {
+			name = foldKey(name)
+			if !seen[name] && strings.TrimSpace(val.String()) != "" {
+				seen[name] = true
+				coverage[name]++
+			}
+		}
+	}
+	return coverage
+}
+
+// Years returns the distinct years of the entries of bib in ascending order,
+// see BibEntry.Year. Entries without a year are skipped.
+func (bib *BibTex) Years() []int {
+	seen := make(map[int]bool)
+	var years []int
+	for _, entry := range bib.Entries {
+		if year, ok := entry.Year(); ok && !seen[year] {
+			seen[year] = true
+			years = append(years, year)
+		}
+	}
+	sort.Ints(years)
+	return years
+}
+
+// RewriteValues replaces the value of every field of every entry with the
+// result of fn, called with the entry type, cite name, field name and the
+// current (displayed) value. Values for which fn returns its input are left
+// unchanged, including any string var references.
+func (bib *BibTex) RewriteValues(fn func(entryType, key, field, value string) string) {
+	for _, entry := range bib.Entries {
+		for name, val := range entry.Fields {
+			if s := fn(entry.Type, entry.CiteName, name, val.String()); s != val.String() {
+				entry.AddField(name, NewBibConst(s))
+			}
+		}
+	}
+}
+
 // AddStringVar adds a new string var (if does not exist).
+// String vars are case-insensitive and stored under their folded key, unless
+// parsed with Parser.CaseSensitiveMacros.
 func (bib *BibTex) AddStringVar(key string, val BibString) {
-	bib.StringVar[key] = &BibVar{Key: key, Value: val}
+	bib.setStringVar(bib.stringVarKey(key), &BibVar{Key: key, Value: val})
+}
+
+// stringVarKey returns the key the string var name is stored under: its folded
+// form, or the name itself if names are case-sensitive.
+func (bib *BibTex) stringVarKey(name string) string {
+	if bib.caseSensitive {
+		return name
+	}
+	return foldKey(name)
+}
+
+// setStringVar sets the string var with the given folded key, keeping track
+// of the order string vars are defined in.
+func (bib *BibTex) setStringVar(key string, v *BibVar) {
+	if _, exists := bib.StringVar[key]; !exists {
+		bib.stringOrder = append(bib.stringOrder, key)
+	}
+	bib.StringVar[key] = v
+}
+
+// stringVarKeys returns the keys of StringVar in order of definition. Keys
+// added to StringVar directly follow in sorted order.
+func (bib *BibTex) stringVarKeys() []string {
+	keys := make([]string, 0, len(bib.StringVar))
+	listed := make(map[string]bool, len(bib.stringOrder))
+	for _, key := range bib.stringOrder {
+		if _, ok := bib.StringVar[key]; ok && !listed[key] {
+			listed[key] = true
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for key := range bib.StringVar {
+		if !listed[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// ResolveStrings returns the values of the string vars of bib by folded key,
+// with the string vars they reference expanded by name. Unlike String, this
+// also expands references made before the string var was defined, and reports
+// a CyclicMacroError for string vars that (indirectly) reference themselves.
+// References to undefined string vars keep their parsed value. Chains of any
+// depth are resolved without recursion.
+func (bib *BibTex) ResolveStrings() (map[string]string, error) {
+	type frame struct {
+		key   string      // Folded key of the string var.
+		parts []BibString // Operands of its value left to expand.
+		buf   strings.Builder
+	}
+	resolved := make(map[string]string, len(bib.StringVar))
+	onStack := make(map[string]int) // Index in stack by folded key.
+	var stack []*frame
+	push := func(key string) {
+		onStack[key] = len(stack)
+		stack = append(stack, &frame{key: key, parts: operands(bib.StringVar[key].Value)})
+	}
+
+	for _, key := range bib.stringVarKeys() {
+		if _, ok := resolved[key]; !ok {
+			push(key)
+		}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if len(top.parts) == 0 {
+				resolved[top.key] = top.buf.String()
+				delete(onStack, top.key)
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 {
+					stack[len(stack)-1].buf.WriteString(resolved[top.key])
+				}
+				continue
+			}
+			part := top.parts[0]
+			top.parts = top.parts[1:]
+			v, ok := part.(*BibVar)
+			if !ok {
+				top.buf.WriteString(part.String())
+				continue
+			}
+			ref := bib.stringVarKey(v.Key)
+			if val, ok := resolved[ref]; ok {
+				top.buf.WriteString(val)
+			} else if i, ok := onStack[ref]; ok {
+				var cycle []string
+				for _, f := range stack[i:] {
+					cycle = append(cycle, bib.StringVar[f.key].Key)
+				}
+				return nil, &CyclicMacroError{Cycle: append(cycle, bib.StringVar[ref].Key)}
+			} else if _, ok := bib.StringVar[ref]; ok {
+				push(ref)
+			} else {
+				top.buf.WriteString(v.String())
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// operands returns the constants and string vars that make up s, in order.
+func operands(s BibString) []BibString {
+	var parts []BibString
+	pending := []BibString{s}
+	for len(pending) > 0 {
+		s, pending = pending[0], pending[1:]
+		if comp, ok := s.(*BibComposite); ok {
+			pending = append(append([]BibString{}, *comp...), pending...)
+			continue
+		}
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+// Subset returns a new BibTex with the entries of bib whose cite names are in
+// keys (case-insensitively), in their order in bib, and the string vars they
+// use, directly or through other string vars, so that it can be written out
+// on its own. Preambles are kept; entries and values are shared with bib.
+func (bib *BibTex) Subset(keys []string) *BibTex {
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[foldKey(key)] = true
+	}
+	sub := NewBibTex()
+	sub.caseSensitive = bib.caseSensitive
+	sub.defaultVars = bib.defaultVars
+	sub.Preambles = append(sub.Preambles, bib.Preambles...)
+
+	used := make(map[string]bool)
+	var pending []BibString
+	for _, entry := range bib.Entries {
+		if !wanted[foldKey(entry.CiteName)] {
+			continue
+		}
+		sub.AddEntry(entry)
+		for _, name := range entry.fieldNames() {
+			pending = append(pending, entry.Fields[name])
+		}
+	}
+	for len(pending) > 0 {
+		var s BibString
+		s, pending = pending[0], pending[1:]
+		for _, part := range operands(s) {
+			v, ok := part.(*BibVar)
+			if !ok {
+				continue
+			}
+			key := bib.stringVarKey(v.Key)
+			if def, ok := bib.StringVar[key]; ok && !used[key] {
+				used[key] = true
+				pending = append(pending, def.Value)
+			}
+		}
+	}
+	for _, key := range bib.stringVarKeys() {
+		if used[key] {
+			sub.setStringVar(key, bib.StringVar[key])
+		}
+	}
+	return sub
 }
 
 // GetStringVar looks up a string by its key.
 func (bib *BibTex) GetStringVar(key string) *BibVar {
-	if bv, ok := bib.StringVar[key]; ok {
-		return bv
-	}
-	if v, ok := bib.getDefaultVar(key); ok {
+	if v, ok := bib.lookupStringVar(key); ok {
 		return v
 	}
 	// This is undefined.
@@ -211,13 +716,87 @@ func (bib *BibTex) GetStringVar(key string) *BibVar {
 	return nil
 }
 
+// lookupStringVar looks up a string var, including the implicit ones.
+func (bib *BibTex) lookupStringVar(key string) (*BibVar, bool) {
+	if bv, ok := bib.StringVar[bib.stringVarKey(key)]; ok {
+		return bv, true
+	}
+	return bib.getDefaultVar(key)
+}
+
+// ExtractStrings replaces the values of the given field that occur in at
+// least minOccurrences entries by references to new string vars, and returns
+// the new string vars as a map from name to value. Values that are already
+// string var references are left alone.
+func (bib *BibTex) ExtractStrings(field string, minOccurrences int) map[string]string {
+	var values []string // Distinct values in order of first occurrence.
+	count := make(map[string]int)
+	for _, entry := range bib.Entries {
+		val, _ := entry.field(field)
+		if val, ok := val.(BibConst); ok {
+			if count[val.String()] == 0 {
+				values = append(values, val.String())
+			}
+			count[val.String()]++
+		}
+	}
+
+	extracted := make(map[string]string)
+	for _, value := range values {
+		if count[value] < minOccurrences || strings.TrimSpace(value) == "" {
+			continue
+		}
+		name := bib.newStringVarName(value)
+		bib.AddStringVar(name, NewBibConst(value))
+		extracted[name] = value
+		for _, entry := range bib.Entries {
+			stored, ok := entry.fieldName(field)
+			if !ok {
+				continue
+			}
+			if val, ok := entry.Fields[stored].(BibConst); ok && val.String() == value {
+				entry.AddField(stored, bib.GetStringVar(name))
+			}
+		}
+	}
+	return extracted
+}
+
+// newStringVarName generates an unused string var name for value, from the
+// initials of its words (e.g. jomlr for Journal of Machine Learning Research).
+func (bib *BibTex) newStringVarName(value string) string {
+	var initials, all strings.Builder
+	for _, word := range strings.FieldsFunc(value, func(r rune) bool {
+		return !isAlphanum(r)
+	}) {
+		initials.WriteByte(word[0])
+		all.WriteString(word)
+	}
+	base := strings.ToLower(initials.String())
+	if len(base) < 2 {
+		base = strings.ToLower(all.String())
+	}
+	if base == "" || !unicode.IsLetter(rune(base[0])) {
+		base = "str" + base
+	}
+	name := base
+	for i := 2; ; i++ {
+		_, defined := bib.StringVar[bib.stringVarKey(name)]
+		_, implicit := bib.defaultVars[bib.stringVarKey(name)]
+		if !defined && !implicit {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
 // getDefaultVar is a fallback for looking up keys (e.g. 3-character month)
 // and use them even though it hasn't been defined in the bib.
 func (bib *BibTex) getDefaultVar(key string) (*BibVar, bool) {
-	if v, ok := bib.defaultVars[key]; ok {
+	if v, ok := bib.defaultVars[bib.stringVarKey(key)]; ok {
 		// if found, add this to the BibTex
-		bib.StringVar[key] = &BibVar{Key: key, Value: NewBibConst(v)}
-		return bib.StringVar[key], true
+		bib.setStringVar(bib.stringVarKey(key), &BibVar{Key: key, Value: NewBibConst(v)})
+		return bib.StringVar[bib.stringVarKey(key)], true
 	}
 
 	return nil, false
@@ -235,8 +814,12 @@ func (bib *BibTex) String() string {
 // RawString returns a BibTex data structure in its internal representation.
 func (bib *BibTex) RawString() string {
 	var bibtex bytes.Buffer
-	for k, strvar := range bib.StringVar {
-		bibtex.WriteString(fmt.Sprintf("@string{%s = {%s}}\n", k, strvar.String()))
+	for _, k := range bib.stringVarKeys() {
+		strvar := bib.StringVar[k]
+		if v, ok := bib.defaultVars[k]; ok && strvar.Value == NewBibConst(v) {
+			continue // Implicitly defined, no need to write it out.
+		}
+		bibtex.WriteString(fmt.Sprintf("@string{%s = %s}\n", strvar.Key, strvar.Value.RawString()))
 	}
 	for _, preamble := range bib.Preambles {
 		bibtex.WriteString(fmt.Sprintf("@preamble{%s}\n", preamble.RawString()))
@@ -249,53 +832,22 @@ func (bib *BibTex) RawString() string {
 
 // PrettyString pretty prints a BibTex.
 func (bib *BibTex) PrettyString() string {
-	var buf bytes.Buffer
-	for i, entry := range bib.Entries {
-		if i != 0 {
-			fmt.Fprint(&buf, "\n")
-		}
-		fmt.Fprintf(&buf, "@%s{%s,\n", entry.Type, entry.CiteName)
-
-		// Determine key order.
-		keys := []string{}
-		for key := range entry.Fields {
-			keys = append(keys, key)
-		}
-
-		priority := map[string]int{"title": -3, "author": -2, "url": -1}
-		sort.Slice(keys, func(i, j int) bool {
-			pi, pj := priority[keys[i]], priority[keys[j]]
-			return pi < pj || (pi == pj && keys[i] < keys[j])
-		})
-
-		// Write fields.
-		tw := tabwriter.NewWriter(&buf, 1, 4, 1, ' ', 0)
-		for _, key := range keys {
-			value := entry.Fields[key].String()
-			format := stringformat(value)
-			fmt.Fprintf(tw, "    %s\t=\t"+format+",\n", key, value)
-		}
-		tw.Flush()
-
-		// Close.
-		buf.WriteString("}\n")
-	}
-	return buf.String()
-
+	return NewFormatter().Format(bib)
 }
 
-// stringformat determines the correct formatting verb for the given BibTeX field value.
-func stringformat(v string) string {
-	// Numbers may be represented unquoted.
-	if _, err := strconv.Atoi(v); err == nil {
-		return "%s"
+// foldKey returns the Unicode case-folded form of a key, used wherever BibTeX
+// compares names case-insensitively (e.g. string vars and field names).
+func foldKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if key[i] >= utf8.RuneSelf {
+			return cases.Fold().String(key)
+		}
 	}
-
-	// Strings with certain characters must be brace quoted.
-	if strings.ContainsAny(v, "\"{}") {
-		return "{%s}"
+	// Folding is lowercasing for ASCII, avoid allocating if already folded.
+	for i := 0; i < len(key); i++ {
+		if 'A' <= key[i] && key[i] <= 'Z' {
+			return strings.ToLower(key)
+		}
 	}
-
-	// Default to quoted string.
-	return "%q"
+	return key
 }
@@ -2,15 +2,19 @@
 package bibtex
 
 import (
+	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 type bibTag struct {
-	key string
-	val BibString
+	key   string
+	val   BibString
+	delim DelimiterKind
+	pos   tokenPos
 }
-
-var bib *BibTex // Only for holding current bib
 %}
 
 %union {
@@ -20,6 +24,8 @@ var bib *BibTex // Only for holding current bib
 	bibtag   *bibTag
 	bibtags  []*bibTag
 	strings  BibString
+	delim    DelimiterKind
+	pos      tokenPos
 }
 
 %token tCOMMENT tSTRING tPREAMBLE
@@ -36,23 +42,29 @@ var bib *BibTex // Only for holding current bib
 top : bibtex { }
     ;
 
-bibtex : /* empty */          { $$ = NewBibTex(); bib = $$ }
+bibtex : /* empty */          { $$ = bibtexlex.(*lexer).newBibTex() }
        | bibtex bibentry      { $$ = $1; $$.AddEntry($2) }
        | bibtex commententry  { $$ = $1 }
-       | bibtex stringentry   { $$ = $1; $$.AddStringVar($2.key, $2.val) }
+       | bibtex stringentry   { $$ = $1; bibtexlex.(*lexer).addStringVar($2) }
        | bibtex preambleentry { $$ = $1; $$.AddPreamble($2) }
        ;
 
-bibentry : tATSIGN tBAREIDENT tLBRACE tBAREIDENT tCOMMA tags tRBRACE { $$ = NewBibEntry($2, $4); for _, t := range $6 { $$.AddField(t.key, t.val) } }
-         | tATSIGN tBAREIDENT tLPAREN tBAREIDENT tCOMMA tags tRPAREN { $$ = NewBibEntry($2, $4); for _, t := range $6 { $$.AddField(t.key, t.val) } }
+bibentry : tATSIGN tBAREIDENT tLBRACE tBAREIDENT tCOMMA tags tRBRACE { $$ = bibtexlex.(*lexer).newEntry($2, $4, $6, $<pos>1) }
+         | tATSIGN tBAREIDENT tLPAREN tBAREIDENT tCOMMA tags tRPAREN { $$ = bibtexlex.(*lexer).newEntry($2, $4, $6, $<pos>1) }
+         | tATSIGN tBAREIDENT tLBRACE tBAREIDENT tEQUAL { $$ = NewBibEntry($2, $4); bibtexlex.(*lexer).setError(&ErrParse{Pos: $<pos>5, Err: ErrUnexpectedEqual.Error()}) }
+         | tATSIGN tBAREIDENT tLPAREN tBAREIDENT tEQUAL { $$ = NewBibEntry($2, $4); bibtexlex.(*lexer).setError(&ErrParse{Pos: $<pos>5, Err: ErrUnexpectedEqual.Error()}) }
          ;
 
-commententry : tATSIGN tCOMMENT tLBRACE longstring tRBRACE {}
-             | tATSIGN tCOMMENT tLPAREN longstring tRBRACE {}
+commententry : tATSIGN tCOMMENT tLBRACE longstring tRBRACE { bibtexlex.(*lexer).comment = false }
+             | tATSIGN tCOMMENT tLPAREN longstring tRPAREN { bibtexlex.(*lexer).comment = false }
              ;
 
-stringentry : tATSIGN tSTRING tLBRACE tBAREIDENT tEQUAL longstring tRBRACE { $$ = &bibTag{key: $4, val: $6 } }
-            | tATSIGN tSTRING tLPAREN tBAREIDENT tEQUAL longstring tRBRACE { $$ = &bibTag{key: $4, val: $6 } }
+stringentry : tATSIGN tSTRING tLBRACE tBAREIDENT tEQUAL longstring tRBRACE { $$ = &bibTag{key: $4, val: $6, pos: $<pos>4} }
+            | tATSIGN tSTRING tLPAREN tBAREIDENT tEQUAL longstring tRPAREN { $$ = &bibTag{key: $4, val: $6, pos: $<pos>4} }
+            | tATSIGN tSTRING tLBRACE tRBRACE { $$ = &bibTag{val: NewBibConst("")}; bibtexlex.(*lexer).setError(&ErrParse{Pos: $<pos>4, Err: ErrEmptyStringEntry.Error()}) }
+            | tATSIGN tSTRING tLPAREN tRPAREN { $$ = &bibTag{val: NewBibConst("")}; bibtexlex.(*lexer).setError(&ErrParse{Pos: $<pos>4, Err: ErrEmptyStringEntry.Error()}) }
+            | tATSIGN tSTRING tLBRACE tEQUAL  { $$ = &bibTag{val: NewBibConst("")}; bibtexlex.(*lexer).setError(&ErrParse{Pos: $<pos>4, Err: ErrEmptyStringEntry.Error()}) }
+            | tATSIGN tSTRING tLPAREN tEQUAL  { $$ = &bibTag{val: NewBibConst("")}; bibtexlex.(*lexer).setError(&ErrParse{Pos: $<pos>4, Err: ErrEmptyStringEntry.Error()}) }
             ;
 
 preambleentry : tATSIGN tPREAMBLE tLBRACE longstring tRBRACE { $$ = $4 }
@@ -60,29 +72,163 @@ preambleentry : tATSIGN tPREAMBLE tLBRACE longstring tRBRACE { $$ = $4 }
               ;
 
 longstring :                  tIDENT     { $$ = NewBibConst($1) }
-           |                  tBAREIDENT { $$ = bib.GetStringVar($1) }
-           | longstring tPOUND tIDENT     { $$ = NewBibComposite($1); $$.(*BibComposite).Append(NewBibConst($3))}
-           | longstring tPOUND tBAREIDENT { $$ = NewBibComposite($1); $$.(*BibComposite).Append(bib.GetStringVar($3)) }
+           |                  tBAREIDENT { $$ = bibtexlex.(*lexer).stringVar($1, $<pos>1); $<delim>$ = DelimMacro }
+           | longstring tPOUND tIDENT     { $$ = concat($1, NewBibConst($3)); $<delim>$ = DelimConcat }
+           | longstring tPOUND tBAREIDENT { $$ = concat($1, bibtexlex.(*lexer).stringVar($3, $<pos>3)); $<delim>$ = DelimConcat }
            ;
 
-tag : /* empty */                { }
-    | tBAREIDENT tEQUAL longstring { $$ = &bibTag{key: $1, val: $3} }
+tag : /* empty */                { $$ = nil }
+    | tBAREIDENT tEQUAL longstring { $$ = &bibTag{key: $1, val: $3, delim: $<delim>3, pos: $<pos>1} }
     ;
 
-tags : tag            { $$ = []*bibTag{$1} }
+tags : tag            { if $1 == nil { $$ = nil } else { $$ = []*bibTag{$1} } }
      | tags tCOMMA tag { if $3 == nil { $$ = $1 } else { $$ = append($1, $3) } }
      ;
 
 %%
 
+// newEntry returns the parsed entry with the given fields, starting with the @
+// at pos.
+func (l *lexer) newEntry(entryType, citeName string, tags []*bibTag, pos tokenPos) *BibEntry {
+	entry := NewBibEntry(entryType, citeName)
+	entry.verbatim = l.verbatim
+	l.addTags(entry, tags)
+	entry.Raw = l.raw
+	if l.parser.TrackPositions {
+		entry.Line = len(pos.Lines) + 1
+	}
+	return entry
+}
+
+// addTags adds parsed fields (key-value) to a BibTeX entry.
+// A field defined more than once, or a field name with whitespace in it, is an
+// error in strict mode. Otherwise the last definition is kept, whitespace is
+// removed from the name and a warning recorded.
+func (l *lexer) addTags(entry *BibEntry, tags []*bibTag) {
+	seen := l.seen
+	for name := range seen {
+		delete(seen, name)
+	}
+	for _, t := range tags {
+		name := strings.TrimSpace(t.key)
+		if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+			err := &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %q", ErrFieldNameSpace, name)}
+			if l.parser.Strict {
+				l.setError(err)
+			} else {
+				l.warn(err)
+				name = strings.Join(strings.Fields(name), "")
+			}
+		}
+		if seen[foldKey(name)] {
+			err := &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrDuplicateField, name)}
+			if l.parser.Strict {
+				l.setError(err)
+			} else {
+				l.warn(err)
+			}
+		}
+		seen[foldKey(name)] = true
+		l.checkValue(name, t)
+		if l.parser.NormalizeUnicode && !entry.isVerbatim(name) {
+			t.val = normalizeNFC(t.val)
+		}
+		if c, ok := t.val.(BibConst); ok && l.parser.NormalizePages && foldKey(name) == "pages" {
+			t.val = NewBibConst(normalizePages(string(c)))
+		}
+		entry.AddField(name, t.val)
+		entry.delims[name] = t.delim
+	}
+}
+
+// checkValue records diagnostics for a likely mistake in the value of the
+// named field: an empty value, or an author list separated by commas.
+func (l *lexer) checkValue(name string, t *bibTag) {
+	value := t.val.String()
+	switch {
+	case strings.TrimSpace(value) == "":
+		l.diagnose(SeverityInfo, &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrEmptyField, name)})
+	case foldKey(name) == "author" && suspiciousAuthorList(value):
+		l.diagnose(SeverityInfo, &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrSuspiciousAuthors, value)})
+	}
+}
+
+// newBibTex starts the BibTex being parsed, with the initial string vars of
+// the parser.
+func (l *lexer) newBibTex() *BibTex {
+	l.bib = NewBibTex()
+	l.bib.caseSensitive = l.parser.CaseSensitiveMacros
+	l.verbatim = verbatimSet(l.parser.VerbatimFields)
+	for key, val := range l.parser.InitialStrings {
+		l.bib.defaultVars[l.bib.stringVarKey(key)] = val
+	}
+	return l.bib
+}
+
+// addStringVar adds the parsed @string definition to the BibTex being parsed.
+func (l *lexer) addStringVar(t *bibTag) {
+	if _, exists := l.bib.StringVar[l.bib.stringVarKey(t.key)]; exists {
+		l.diagnose(SeverityInfo, &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrRedefinedStringVar, t.key)})
+	}
+	val := t.val
+	if l.parser.NormalizeUnicode {
+		val = normalizeNFC(val)
+	}
+	l.bib.AddStringVar(t.key, val)
+}
+
+// stringVar returns the string var referenced by key. A bare word that is not
+// a defined string var is an error in strict mode. Otherwise it is kept as a
+// string var with the word as its value, and a warning recorded.
+func (l *lexer) stringVar(key string, pos tokenPos) BibString {
+	if v, ok := l.bib.lookupStringVar(key); ok {
+		return v
+	}
+	v := &BibVar{Key: key, Value: NewBibConst(key), undefined: true}
+	if l.comment { // Comments are discarded, words in them are not vars.
+		return v
+	}
+	err := &ErrParse{Pos: pos, Err: fmt.Sprintf("%s: %s", ErrUnknownStringVar, key)}
+	if l.parser.Strict {
+		l.setError(err)
+	} else {
+		l.warn(err)
+	}
+	return v
+}
+
 // Parse is the entry point to the bibtex parser.
 func Parse(r io.Reader) (*BibTex, error) {
-	l := newLexer(r)
-	bibtexParse(l)
+	return new(Parser).Parse(r)
+}
+
+// Parse parses a BibTeX file using the options set in p.
+// A Parser reuses its internal buffers between calls, but must not be used by
+// more than one goroutine at a time.
+func (p *Parser) Parse(r io.Reader) (*BibTex, error) {
+	p.Reset(r)
+	p.yacc.Parse(p.lexer)
+	sort.SliceStable(p.Diagnostics, func(i, j int) bool {
+		return p.Diagnostics[i].Pos.before(p.Diagnostics[j].Pos)
+	})
 	select {
-	case err := <-l.Errors:
+	case err := <-p.lexer.Errors:
 		return nil, err
 	default:
-		return bib, nil
+		return p.lexer.bib, nil
+	}
+}
+
+// Reset discards the state of the previous parse and makes p read from r.
+// Parse calls Reset itself, so it is only needed to release the last input
+// and result (with a nil r) before putting p back into a pool, e.g. sync.Pool.
+func (p *Parser) Reset(r io.Reader) {
+	p.Warnings = nil
+	p.Diagnostics = nil
+	p.yacc = bibtexParserImpl{}
+	if p.lexer == nil {
+		p.lexer = newLexer(r, p)
+		return
 	}
+	p.lexer.reset(r)
 }
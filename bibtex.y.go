@@ -8,17 +8,21 @@ import __yyfmt__ "fmt"
 //line bibtex.y:2
 
 import (
+	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 type bibTag struct {
-	key string
-	val BibString
+	key   string
+	val   BibString
+	delim DelimiterKind
+	pos   tokenPos
 }
 
-var bib *BibTex // Only for holding current bib
-
-//line bibtex.y:16
+//line bibtex.y:20
 type bibtexSymType struct {
 	yys      int
 	bibtex   *BibTex
@@ -27,6 +31,8 @@ type bibtexSymType struct {
 	bibtag   *bibTag
 	bibtags  []*bibTag
 	strings  BibString
+	delim    DelimiterKind
+	pos      tokenPos
 }
 
 const tCOMMENT = 57346
@@ -65,28 +71,163 @@ var bibtexToknames = [...]string{
 	"tBAREIDENT",
 	"tIDENT",
 }
+
 var bibtexStatenames = [...]string{}
 
 const bibtexEofCode = 1
 const bibtexErrCode = 2
 const bibtexInitialStackSize = 16
 
-//line bibtex.y:76
+//line bibtex.y:88
+
+// newEntry returns the parsed entry with the given fields, starting with the @
+// at pos.
+func (l *lexer) newEntry(entryType, citeName string, tags []*bibTag, pos tokenPos) *BibEntry {
+	entry := NewBibEntry(entryType, citeName)
+	entry.verbatim = l.verbatim
+	l.addTags(entry, tags)
+	entry.Raw = l.raw
+	if l.parser.TrackPositions {
+		entry.Line = len(pos.Lines) + 1
+	}
+	return entry
+}
+
+// addTags adds parsed fields (key-value) to a BibTeX entry.
+// A field defined more than once, or a field name with whitespace in it, is an
+// error in strict mode. Otherwise the last definition is kept, whitespace is
+// removed from the name and a warning recorded.
+func (l *lexer) addTags(entry *BibEntry, tags []*bibTag) {
+	seen := l.seen
+	for name := range seen {
+		delete(seen, name)
+	}
+	for _, t := range tags {
+		name := strings.TrimSpace(t.key)
+		if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+			err := &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %q", ErrFieldNameSpace, name)}
+			if l.parser.Strict {
+				l.setError(err)
+			} else {
+				l.warn(err)
+				name = strings.Join(strings.Fields(name), "")
+			}
+		}
+		if seen[foldKey(name)] {
+			err := &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrDuplicateField, name)}
+			if l.parser.Strict {
+				l.setError(err)
+			} else {
+				l.warn(err)
+			}
+		}
+		seen[foldKey(name)] = true
+		l.checkValue(name, t)
+		if l.parser.NormalizeUnicode && !entry.isVerbatim(name) {
+			t.val = normalizeNFC(t.val)
+		}
+		if c, ok := t.val.(BibConst); ok && l.parser.NormalizePages && foldKey(name) == "pages" {
+			t.val = NewBibConst(normalizePages(string(c)))
+		}
+		entry.AddField(name, t.val)
+		entry.delims[name] = t.delim
+	}
+}
+
+// checkValue records diagnostics for a likely mistake in the value of the
+// named field: an empty value, or an author list separated by commas.
+func (l *lexer) checkValue(name string, t *bibTag) {
+	value := t.val.String()
+	switch {
+	case strings.TrimSpace(value) == "":
+		l.diagnose(SeverityInfo, &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrEmptyField, name)})
+	case foldKey(name) == "author" && suspiciousAuthorList(value):
+		l.diagnose(SeverityInfo, &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrSuspiciousAuthors, value)})
+	}
+}
+
+// newBibTex starts the BibTex being parsed, with the initial string vars of
+// the parser.
+func (l *lexer) newBibTex() *BibTex {
+	l.bib = NewBibTex()
+	l.bib.caseSensitive = l.parser.CaseSensitiveMacros
+	l.verbatim = verbatimSet(l.parser.VerbatimFields)
+	for key, val := range l.parser.InitialStrings {
+		l.bib.defaultVars[l.bib.stringVarKey(key)] = val
+	}
+	return l.bib
+}
+
+// addStringVar adds the parsed @string definition to the BibTex being parsed.
+func (l *lexer) addStringVar(t *bibTag) {
+	if _, exists := l.bib.StringVar[l.bib.stringVarKey(t.key)]; exists {
+		l.diagnose(SeverityInfo, &ErrParse{Pos: t.pos, Err: fmt.Sprintf("%s: %s", ErrRedefinedStringVar, t.key)})
+	}
+	val := t.val
+	if l.parser.NormalizeUnicode {
+		val = normalizeNFC(val)
+	}
+	l.bib.AddStringVar(t.key, val)
+}
+
+// stringVar returns the string var referenced by key. A bare word that is not
+// a defined string var is an error in strict mode. Otherwise it is kept as a
+// string var with the word as its value, and a warning recorded.
+func (l *lexer) stringVar(key string, pos tokenPos) BibString {
+	if v, ok := l.bib.lookupStringVar(key); ok {
+		return v
+	}
+	v := &BibVar{Key: key, Value: NewBibConst(key), undefined: true}
+	if l.comment { // Comments are discarded, words in them are not vars.
+		return v
+	}
+	err := &ErrParse{Pos: pos, Err: fmt.Sprintf("%s: %s", ErrUnknownStringVar, key)}
+	if l.parser.Strict {
+		l.setError(err)
+	} else {
+		l.warn(err)
+	}
+	return v
+}
 
 // Parse is the entry point to the bibtex parser.
 func Parse(r io.Reader) (*BibTex, error) {
-	l := newLexer(r)
-	bibtexParse(l)
+	return new(Parser).Parse(r)
+}
+
+// Parse parses a BibTeX file using the options set in p.
+// A Parser reuses its internal buffers between calls, but must not be used by
+// more than one goroutine at a time.
+func (p *Parser) Parse(r io.Reader) (*BibTex, error) {
+	p.Reset(r)
+	p.yacc.Parse(p.lexer)
+	sort.SliceStable(p.Diagnostics, func(i, j int) bool {
+		return p.Diagnostics[i].Pos.before(p.Diagnostics[j].Pos)
+	})
 	select {
-	case err := <-l.Errors:
+	case err := <-p.lexer.Errors:
 		return nil, err
 	default:
-		return bib, nil
+		return p.lexer.bib, nil
+	}
+}
+
+// Reset discards the state of the previous parse and makes p read from r.
+// Parse calls Reset itself, so it is only needed to release the last input
+// and result (with a nil r) before putting p back into a pool, e.g. sync.Pool.
+func (p *Parser) Reset(r io.Reader) {
+	p.Warnings = nil
+	p.Diagnostics = nil
+	p.yacc = bibtexParserImpl{}
+	if p.lexer == nil {
+		p.lexer = newLexer(r, p)
+		return
 	}
+	p.lexer.reset(r)
 }
 
 //line yacctab:1
-var bibtexExca = [...]int{
+var bibtexExca = [...]int8{
 	-1, 1,
 	1, -1,
 	-2, 0,
@@ -94,71 +235,74 @@ var bibtexExca = [...]int{
 
 const bibtexPrivate = 57344
 
-const bibtexLast = 61
+const bibtexLast = 67
 
-var bibtexAct = [...]int{
-
-	22, 39, 40, 41, 9, 10, 11, 24, 23, 44,
-	43, 27, 48, 26, 21, 20, 25, 8, 50, 28,
-	29, 33, 33, 49, 18, 16, 38, 19, 17, 14,
-	31, 12, 15, 42, 13, 30, 45, 46, 33, 33,
-	52, 51, 48, 36, 33, 47, 37, 33, 35, 34,
-	54, 53, 33, 7, 32, 4, 1, 6, 5, 3,
-	2,
+var bibtexAct = [...]int8{
+	22, 45, 46, 47, 9, 10, 11, 24, 23, 50,
+	49, 21, 20, 39, 31, 39, 25, 8, 58, 32,
+	33, 30, 29, 28, 39, 7, 54, 27, 39, 44,
+	55, 26, 56, 40, 18, 42, 16, 19, 48, 17,
+	41, 14, 51, 52, 15, 12, 4, 39, 13, 57,
+	54, 1, 39, 53, 43, 6, 60, 59, 39, 5,
+	38, 37, 36, 35, 34, 3, 2,
 }
-var bibtexPact = [...]int{
 
-	-1000, -1000, 46, -1000, -1000, -1000, -1000, 0, 19, 17,
-	13, 12, -2, -3, -10, -10, -4, -6, -10, -10,
-	25, 20, 41, -1000, -1000, 36, 39, 34, 33, 10,
-	-14, -14, -1000, -8, -1000, -10, -10, -1000, -1000, 32,
-	-1000, 14, 2, -1000, -1000, 28, 27, -1000, -14, -10,
-	-1000, -1000, -1000, -1000, 11,
+var bibtexPact = [...]int16{
+	-1000, -1000, 18, -1000, -1000, -1000, -1000, 0, 33, 29,
+	24, 22, -5, -6, -10, -10, 14, 5, -10, -10,
+	54, 52, 47, -1000, -1000, 17, 31, -1000, -1000, 26,
+	-1000, -1000, 41, 13, -14, -1000, -14, -1000, -1000, -8,
+	-1000, -10, -10, -1000, -1000, 40, -1000, 21, 16, -1000,
+	-1000, 36, 2, -1000, -14, -10, -1000, -1000, -1000, -1000,
+	4,
 }
-var bibtexPgo = [...]int{
 
-	0, 60, 59, 2, 58, 1, 0, 57, 56, 55,
+var bibtexPgo = [...]int8{
+	0, 66, 65, 2, 59, 1, 0, 55, 51, 46,
 }
-var bibtexR1 = [...]int{
 
-	0, 8, 1, 1, 1, 1, 1, 2, 2, 9,
-	9, 4, 4, 7, 7, 6, 6, 6, 6, 3,
-	3, 5, 5,
+var bibtexR1 = [...]int8{
+	0, 8, 1, 1, 1, 1, 1, 2, 2, 2,
+	2, 9, 9, 4, 4, 4, 4, 4, 4, 7,
+	7, 6, 6, 6, 6, 3, 3, 5, 5,
 }
-var bibtexR2 = [...]int{
 
+var bibtexR2 = [...]int8{
 	0, 1, 0, 2, 2, 2, 2, 7, 7, 5,
-	5, 7, 7, 5, 5, 1, 1, 3, 3, 0,
-	3, 1, 3,
+	5, 5, 5, 7, 7, 4, 4, 4, 4, 5,
+	5, 1, 1, 3, 3, 0, 3, 1, 3,
 }
-var bibtexChk = [...]int{
 
+var bibtexChk = [...]int16{
 	-1000, -8, -1, -2, -9, -4, -7, 7, 17, 4,
 	5, 6, 12, 15, 12, 15, 12, 15, 12, 15,
-	17, 17, -6, 18, 17, -6, 17, 17, -6, -6,
-	10, 10, 13, 11, 13, 9, 9, 13, 16, -5,
-	-3, 17, -5, 18, 17, -6, -6, 13, 10, 9,
-	16, 13, 13, -3, -6,
+	17, 17, -6, 18, 17, -6, 17, 13, 9, 17,
+	16, 9, -6, -6, 10, 9, 10, 9, 13, 11,
+	16, 9, 9, 13, 16, -5, -3, 17, -5, 18,
+	17, -6, -6, 13, 10, 9, 16, 13, 16, -3,
+	-6,
 }
-var bibtexDef = [...]int{
 
+var bibtexDef = [...]int8{
 	2, -2, 1, 3, 4, 5, 6, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 15, 16, 0, 0, 0, 0, 0,
-	19, 19, 9, 0, 10, 0, 0, 13, 14, 0,
-	21, 0, 0, 17, 18, 0, 0, 7, 19, 0,
-	8, 11, 12, 22, 20,
+	0, 0, 0, 21, 22, 0, 0, 15, 17, 0,
+	16, 18, 0, 0, 25, 9, 25, 10, 11, 0,
+	12, 0, 0, 19, 20, 0, 27, 0, 0, 23,
+	24, 0, 0, 7, 25, 0, 8, 13, 14, 28,
+	26,
 }
-var bibtexTok1 = [...]int{
 
+var bibtexTok1 = [...]int8{
 	1,
 }
-var bibtexTok2 = [...]int{
 
+var bibtexTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18,
 }
-var bibtexTok3 = [...]int{
+
+var bibtexTok3 = [...]int8{
 	0,
 }
 
@@ -240,9 +384,9 @@ func bibtexErrorMessage(state, lookAhead int) string {
 	expected := make([]int, 0, 4)
 
 	// Look for shiftable tokens.
-	base := bibtexPact[state]
+	base := int(bibtexPact[state])
 	for tok := TOKSTART; tok-1 < len(bibtexToknames); tok++ {
-		if n := base + tok; n >= 0 && n < bibtexLast && bibtexChk[bibtexAct[n]] == tok {
+		if n := base + tok; n >= 0 && n < bibtexLast && int(bibtexChk[int(bibtexAct[n])]) == tok {
 			if len(expected) == cap(expected) {
 				return res
 			}
@@ -252,13 +396,13 @@ func bibtexErrorMessage(state, lookAhead int) string {
 
 	if bibtexDef[state] == -2 {
 		i := 0
-		for bibtexExca[i] != -1 || bibtexExca[i+1] != state {
+		for bibtexExca[i] != -1 || int(bibtexExca[i+1]) != state {
 			i += 2
 		}
 
 		// Look for tokens that we accept or reduce.
 		for i += 2; bibtexExca[i] >= 0; i += 2 {
-			tok := bibtexExca[i]
+			tok := int(bibtexExca[i])
 			if tok < TOKSTART || bibtexExca[i+1] == 0 {
 				continue
 			}
@@ -289,30 +433,30 @@ func bibtexlex1(lex bibtexLexer, lval *bibtexSymType) (char, token int) {
 	token = 0
 	char = lex.Lex(lval)
 	if char <= 0 {
-		token = bibtexTok1[0]
+		token = int(bibtexTok1[0])
 		goto out
 	}
 	if char < len(bibtexTok1) {
-		token = bibtexTok1[char]
+		token = int(bibtexTok1[char])
 		goto out
 	}
 	if char >= bibtexPrivate {
 		if char < bibtexPrivate+len(bibtexTok2) {
-			token = bibtexTok2[char-bibtexPrivate]
+			token = int(bibtexTok2[char-bibtexPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(bibtexTok3); i += 2 {
-		token = bibtexTok3[i+0]
+		token = int(bibtexTok3[i+0])
 		if token == char {
-			token = bibtexTok3[i+1]
+			token = int(bibtexTok3[i+1])
 			goto out
 		}
 	}
 
 out:
 	if token == 0 {
-		token = bibtexTok2[1] /* unknown char */
+		token = int(bibtexTok2[1]) /* unknown char */
 	}
 	if bibtexDebug >= 3 {
 		__yyfmt__.Printf("lex %s(%d)\n", bibtexTokname(token), uint(char))
@@ -367,7 +511,7 @@ bibtexstack:
 	bibtexS[bibtexp].yys = bibtexstate
 
 bibtexnewstate:
-	bibtexn = bibtexPact[bibtexstate]
+	bibtexn = int(bibtexPact[bibtexstate])
 	if bibtexn <= bibtexFlag {
 		goto bibtexdefault /* simple state */
 	}
@@ -378,8 +522,8 @@ bibtexnewstate:
 	if bibtexn < 0 || bibtexn >= bibtexLast {
 		goto bibtexdefault
 	}
-	bibtexn = bibtexAct[bibtexn]
-	if bibtexChk[bibtexn] == bibtextoken { /* valid shift */
+	bibtexn = int(bibtexAct[bibtexn])
+	if int(bibtexChk[bibtexn]) == bibtextoken { /* valid shift */
 		bibtexrcvr.char = -1
 		bibtextoken = -1
 		bibtexVAL = bibtexrcvr.lval
@@ -392,7 +536,7 @@ bibtexnewstate:
 
 bibtexdefault:
 	/* default state action */
-	bibtexn = bibtexDef[bibtexstate]
+	bibtexn = int(bibtexDef[bibtexstate])
 	if bibtexn == -2 {
 		if bibtexrcvr.char < 0 {
 			bibtexrcvr.char, bibtextoken = bibtexlex1(bibtexlex, &bibtexrcvr.lval)
@@ -401,18 +545,18 @@ bibtexdefault:
 		/* look through exception table */
 		xi := 0
 		for {
-			if bibtexExca[xi+0] == -1 && bibtexExca[xi+1] == bibtexstate {
+			if bibtexExca[xi+0] == -1 && int(bibtexExca[xi+1]) == bibtexstate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			bibtexn = bibtexExca[xi+0]
+			bibtexn = int(bibtexExca[xi+0])
 			if bibtexn < 0 || bibtexn == bibtextoken {
 				break
 			}
 		}
-		bibtexn = bibtexExca[xi+1]
+		bibtexn = int(bibtexExca[xi+1])
 		if bibtexn < 0 {
 			goto ret0
 		}
@@ -434,10 +578,10 @@ bibtexdefault:
 
 			/* find a state where "error" is a legal shift action */
 			for bibtexp >= 0 {
-				bibtexn = bibtexPact[bibtexS[bibtexp].yys] + bibtexErrCode
+				bibtexn = int(bibtexPact[bibtexS[bibtexp].yys]) + bibtexErrCode
 				if bibtexn >= 0 && bibtexn < bibtexLast {
-					bibtexstate = bibtexAct[bibtexn] /* simulate a shift of "error" */
-					if bibtexChk[bibtexstate] == bibtexErrCode {
+					bibtexstate = int(bibtexAct[bibtexn]) /* simulate a shift of "error" */
+					if int(bibtexChk[bibtexstate]) == bibtexErrCode {
 						goto bibtexstack
 					}
 				}
@@ -473,7 +617,7 @@ bibtexdefault:
 	bibtexpt := bibtexp
 	_ = bibtexpt // guard against "declared and not used"
 
-	bibtexp -= bibtexR2[bibtexn]
+	bibtexp -= int(bibtexR2[bibtexn])
 	// bibtexp is now the index of $0. Perform the default action. Iff the
 	// reduced production is ε, $1 is possibly out of range.
 	if bibtexp+1 >= len(bibtexS) {
@@ -484,16 +628,16 @@ bibtexdefault:
 	bibtexVAL = bibtexS[bibtexp+1]
 
 	/* consult goto table to find next state */
-	bibtexn = bibtexR1[bibtexn]
-	bibtexg := bibtexPgo[bibtexn]
+	bibtexn = int(bibtexR1[bibtexn])
+	bibtexg := int(bibtexPgo[bibtexn])
 	bibtexj := bibtexg + bibtexS[bibtexp].yys + 1
 
 	if bibtexj >= bibtexLast {
-		bibtexstate = bibtexAct[bibtexg]
+		bibtexstate = int(bibtexAct[bibtexg])
 	} else {
-		bibtexstate = bibtexAct[bibtexj]
-		if bibtexChk[bibtexstate] != -bibtexn {
-			bibtexstate = bibtexAct[bibtexg]
+		bibtexstate = int(bibtexAct[bibtexj])
+		if int(bibtexChk[bibtexstate]) != -bibtexn {
+			bibtexstate = int(bibtexAct[bibtexg])
 		}
 	}
 	// dummy call; replaced with literal code
@@ -501,141 +645,184 @@ bibtexdefault:
 
 	case 1:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-//line bibtex.y:36
+//line bibtex.y:42
 		{
 		}
 	case 2:
 		bibtexDollar = bibtexS[bibtexpt-0 : bibtexpt+1]
-//line bibtex.y:39
+//line bibtex.y:45
 		{
-			bibtexVAL.bibtex = NewBibTex()
-			bib = bibtexVAL.bibtex
+			bibtexVAL.bibtex = bibtexlex.(*lexer).newBibTex()
 		}
 	case 3:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-//line bibtex.y:40
+//line bibtex.y:46
 		{
 			bibtexVAL.bibtex = bibtexDollar[1].bibtex
 			bibtexVAL.bibtex.AddEntry(bibtexDollar[2].bibentry)
 		}
 	case 4:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-//line bibtex.y:41
+//line bibtex.y:47
 		{
 			bibtexVAL.bibtex = bibtexDollar[1].bibtex
 		}
 	case 5:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-//line bibtex.y:42
+//line bibtex.y:48
 		{
 			bibtexVAL.bibtex = bibtexDollar[1].bibtex
-			bibtexVAL.bibtex.AddStringVar(bibtexDollar[2].bibtag.key, bibtexDollar[2].bibtag.val)
+			bibtexlex.(*lexer).addStringVar(bibtexDollar[2].bibtag)
 		}
 	case 6:
 		bibtexDollar = bibtexS[bibtexpt-2 : bibtexpt+1]
-//line bibtex.y:43
+//line bibtex.y:49
 		{
 			bibtexVAL.bibtex = bibtexDollar[1].bibtex
 			bibtexVAL.bibtex.AddPreamble(bibtexDollar[2].strings)
 		}
 	case 7:
 		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-//line bibtex.y:46
+//line bibtex.y:52
 		{
-			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
-			for _, t := range bibtexDollar[6].bibtags {
-				bibtexVAL.bibentry.AddField(t.key, t.val)
-			}
+			bibtexVAL.bibentry = bibtexlex.(*lexer).newEntry(bibtexDollar[2].strval, bibtexDollar[4].strval, bibtexDollar[6].bibtags, bibtexDollar[1].pos)
 		}
 	case 8:
 		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-//line bibtex.y:47
+//line bibtex.y:53
 		{
-			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
-			for _, t := range bibtexDollar[6].bibtags {
-				bibtexVAL.bibentry.AddField(t.key, t.val)
-			}
+			bibtexVAL.bibentry = bibtexlex.(*lexer).newEntry(bibtexDollar[2].strval, bibtexDollar[4].strval, bibtexDollar[6].bibtags, bibtexDollar[1].pos)
 		}
 	case 9:
 		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-//line bibtex.y:50
+//line bibtex.y:54
 		{
+			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
+			bibtexlex.(*lexer).setError(&ErrParse{Pos: bibtexDollar[5].pos, Err: ErrUnexpectedEqual.Error()})
 		}
 	case 10:
 		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-//line bibtex.y:51
+//line bibtex.y:55
 		{
+			bibtexVAL.bibentry = NewBibEntry(bibtexDollar[2].strval, bibtexDollar[4].strval)
+			bibtexlex.(*lexer).setError(&ErrParse{Pos: bibtexDollar[5].pos, Err: ErrUnexpectedEqual.Error()})
 		}
 	case 11:
-		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-//line bibtex.y:54
+		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
+//line bibtex.y:58
 		{
-			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[4].strval, val: bibtexDollar[6].strings}
+			bibtexlex.(*lexer).comment = false
 		}
 	case 12:
-		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
-//line bibtex.y:55
+		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
+//line bibtex.y:59
 		{
-			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[4].strval, val: bibtexDollar[6].strings}
+			bibtexlex.(*lexer).comment = false
 		}
 	case 13:
+		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
+//line bibtex.y:62
+		{
+			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[4].strval, val: bibtexDollar[6].strings, pos: bibtexDollar[4].pos}
+		}
+	case 14:
+		bibtexDollar = bibtexS[bibtexpt-7 : bibtexpt+1]
+//line bibtex.y:63
+		{
+			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[4].strval, val: bibtexDollar[6].strings, pos: bibtexDollar[4].pos}
+		}
+	case 15:
+		bibtexDollar = bibtexS[bibtexpt-4 : bibtexpt+1]
+//line bibtex.y:64
+		{
+			bibtexVAL.bibtag = &bibTag{val: NewBibConst("")}
+			bibtexlex.(*lexer).setError(&ErrParse{Pos: bibtexDollar[4].pos, Err: ErrEmptyStringEntry.Error()})
+		}
+	case 16:
+		bibtexDollar = bibtexS[bibtexpt-4 : bibtexpt+1]
+//line bibtex.y:65
+		{
+			bibtexVAL.bibtag = &bibTag{val: NewBibConst("")}
+			bibtexlex.(*lexer).setError(&ErrParse{Pos: bibtexDollar[4].pos, Err: ErrEmptyStringEntry.Error()})
+		}
+	case 17:
+		bibtexDollar = bibtexS[bibtexpt-4 : bibtexpt+1]
+//line bibtex.y:66
+		{
+			bibtexVAL.bibtag = &bibTag{val: NewBibConst("")}
+			bibtexlex.(*lexer).setError(&ErrParse{Pos: bibtexDollar[4].pos, Err: ErrEmptyStringEntry.Error()})
+		}
+	case 18:
+		bibtexDollar = bibtexS[bibtexpt-4 : bibtexpt+1]
+//line bibtex.y:67
+		{
+			bibtexVAL.bibtag = &bibTag{val: NewBibConst("")}
+			bibtexlex.(*lexer).setError(&ErrParse{Pos: bibtexDollar[4].pos, Err: ErrEmptyStringEntry.Error()})
+		}
+	case 19:
 		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-//line bibtex.y:58
+//line bibtex.y:70
 		{
 			bibtexVAL.strings = bibtexDollar[4].strings
 		}
-	case 14:
+	case 20:
 		bibtexDollar = bibtexS[bibtexpt-5 : bibtexpt+1]
-//line bibtex.y:59
+//line bibtex.y:71
 		{
 			bibtexVAL.strings = bibtexDollar[4].strings
 		}
-	case 15:
+	case 21:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-//line bibtex.y:62
+//line bibtex.y:74
 		{
 			bibtexVAL.strings = NewBibConst(bibtexDollar[1].strval)
 		}
-	case 16:
+	case 22:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-//line bibtex.y:63
+//line bibtex.y:75
 		{
-			bibtexVAL.strings = bib.GetStringVar(bibtexDollar[1].strval)
+			bibtexVAL.strings = bibtexlex.(*lexer).stringVar(bibtexDollar[1].strval, bibtexDollar[1].pos)
+			bibtexVAL.delim = DelimMacro
 		}
-	case 17:
+	case 23:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-//line bibtex.y:64
+//line bibtex.y:76
 		{
-			bibtexVAL.strings = NewBibComposite(bibtexDollar[1].strings)
-			bibtexVAL.strings.(*BibComposite).Append(NewBibConst(bibtexDollar[3].strval))
+			bibtexVAL.strings = concat(bibtexDollar[1].strings, NewBibConst(bibtexDollar[3].strval))
+			bibtexVAL.delim = DelimConcat
 		}
-	case 18:
+	case 24:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-//line bibtex.y:65
+//line bibtex.y:77
 		{
-			bibtexVAL.strings = NewBibComposite(bibtexDollar[1].strings)
-			bibtexVAL.strings.(*BibComposite).Append(bib.GetStringVar(bibtexDollar[3].strval))
+			bibtexVAL.strings = concat(bibtexDollar[1].strings, bibtexlex.(*lexer).stringVar(bibtexDollar[3].strval, bibtexDollar[3].pos))
+			bibtexVAL.delim = DelimConcat
 		}
-	case 19:
+	case 25:
 		bibtexDollar = bibtexS[bibtexpt-0 : bibtexpt+1]
-//line bibtex.y:68
+//line bibtex.y:80
 		{
+			bibtexVAL.bibtag = nil
 		}
-	case 20:
+	case 26:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-//line bibtex.y:69
+//line bibtex.y:81
 		{
-			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[1].strval, val: bibtexDollar[3].strings}
+			bibtexVAL.bibtag = &bibTag{key: bibtexDollar[1].strval, val: bibtexDollar[3].strings, delim: bibtexDollar[3].delim, pos: bibtexDollar[1].pos}
 		}
-	case 21:
+	case 27:
 		bibtexDollar = bibtexS[bibtexpt-1 : bibtexpt+1]
-//line bibtex.y:72
+//line bibtex.y:84
 		{
-			bibtexVAL.bibtags = []*bibTag{bibtexDollar[1].bibtag}
+			if bibtexDollar[1].bibtag == nil {
+				bibtexVAL.bibtags = nil
+			} else {
+				bibtexVAL.bibtags = []*bibTag{bibtexDollar[1].bibtag}
+			}
 		}
-	case 22:
+	case 28:
 		bibtexDollar = bibtexS[bibtexpt-3 : bibtexpt+1]
-//line bibtex.y:73
+//line bibtex.y:85
 		{
 			if bibtexDollar[3].bibtag == nil {
 				bibtexVAL.bibtags = bibtexDollar[1].bibtags
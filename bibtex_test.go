@@ -2,9 +2,11 @@ package bibtex
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -61,6 +63,564 @@ func TestString(t *testing.T) {
 	}
 }
 
+// Tests that string vars are looked up case-insensitively.
+func TestStringVarCaseFold(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{JAHR = {2019}}
+@STRING{Cat = {meow}}
+@article{abcd,
+  year = jahr,
+  title = CAT,
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "2019", bib.Entries[0].Fields["year"].String(); want != got {
+		t.Errorf("Expecting year %q but got %q", want, got)
+	}
+	if want, got := "meow", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := "JAHR", bib.GetStringVar("Jahr").RawString(); want != got {
+		t.Errorf("Expecting var key %q but got %q", want, got)
+	}
+
+	bib.AddStringVar("STRASSE", NewBibConst("street"))
+	if want, got := "street", bib.GetStringVar("straße").String(); want != got {
+		t.Errorf("Expecting %q but got %q", want, got)
+	}
+}
+
+// Tests that RawString keeps string var references and definitions, so that
+// parsing its output gives back the same unexpanded values.
+func TestRawStringRoundTrip(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{ieee = "IEEE Transactions"}
+@string{yr = {2020}}
+@article{abcd,
+  journal = ieee,
+  booktitle = "Proc. " # ieee # " Workshop",
+  year = yr,
+  month = jan,
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib2, err := Parse(strings.NewReader(bib.RawString()))
+	if err != nil {
+		t.Fatalf("Cannot parse RawString output: %v\n%s", err, bib.RawString())
+	}
+	if want, got := len(bib.StringVar), len(bib2.StringVar); want != got {
+		t.Errorf("Expecting %d string vars but got %d", want, got)
+	}
+	if strings.Contains(bib.RawString(), "@string{jan") {
+		t.Errorf("Implicit string var jan should not be written out:\n%s", bib.RawString())
+	}
+	AssertEntryListsEqual(t, bib.Entries, bib2.Entries)
+	for key, expected := range map[string]string{
+		"journal":   "ieee",
+		"booktitle": "{Proc. } # ieee # { Workshop}",
+		"year":      "yr",
+		"month":     "jan",
+	} {
+		if want, got := expected, bib2.Entries[0].Fields[key].RawString(); want != got {
+			t.Errorf("Expecting raw %s %q but got %q", key, want, got)
+		}
+	}
+	if want, got := "Proc. IEEE Transactions Workshop", bib2.Entries[0].Fields["booktitle"].String(); want != got {
+		t.Errorf("Expecting booktitle %q but got %q", want, got)
+	}
+}
+
+// Tests that numbers concatenate with adjacent strings and string vars.
+func TestConcatNumbers(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{vol = {V}}
+@misc{a, volume = 1 # "A", number = "v" # 2, year = 2020 # "a", note = vol # 3 # {b}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string][2]string{
+		"volume": {"1A", "{1} # {A}"},
+		"number": {"v2", "{v} # {2}"},
+		"year":   {"2020a", "{2020} # {a}"},
+		"note":   {"V3b", "vol # {3} # {b}"},
+	} {
+		val := bib.Entries[0].Fields[key]
+		if want, got := expected[0], val.String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", key, want, got)
+		}
+		if want, got := expected[1], val.RawString(); want != got {
+			t.Errorf("Expecting raw %s %q but got %q", key, want, got)
+		}
+	}
+}
+
+func TestSubset(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{acm = {ACM}}
+@string{unused = {Unused}}
+@string{tocs = acm # { Trans. Comput. Syst.}}
+@string{other = {Other}}
+@article{a, journal = tocs, month = jan}
+@article{b, journal = other}
+@misc{C, publisher = acm, note = {N}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := bib.Subset([]string{"c", "a"})
+	var names []string
+	for _, entry := range sub.Entries {
+		names = append(names, entry.CiteName)
+	}
+	if want, got := []string{"a", "C"}, names; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting entries %v but got %v", want, got)
+	}
+	for _, key := range []string{"acm", "tocs"} {
+		if _, ok := sub.StringVar[key]; !ok {
+			t.Errorf("Expecting string var %s to be kept", key)
+		}
+	}
+	for _, key := range []string{"unused", "other"} {
+		if _, ok := sub.StringVar[key]; ok {
+			t.Errorf("Expecting unused string var %s to be excluded", key)
+		}
+	}
+	out, err := Parse(strings.NewReader(sub.RawString()))
+	if err != nil {
+		t.Fatalf("Cannot parse subset: %v\n%s", err, sub.RawString())
+	}
+	if want, got := "ACM Trans. Comput. Syst.", out.Entries[0].Fields["journal"].String(); want != got {
+		t.Errorf("Expecting journal %q but got %q", want, got)
+	}
+	if _, ok := bib.StringVar["unused"]; !ok {
+		t.Errorf("Expecting the original to be unchanged")
+	}
+}
+
+func TestResolveStrings(t *testing.T) {
+	// Defined in reverse, so each reference is made before its definition.
+	bib, err := Parse(strings.NewReader(`@string{a = b # "a"}
+@string{b = c # "b"}
+@string{c = d # "c"}
+@string{d = E # "d"}
+@string{e = "e" # jan}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := bib.ResolveStrings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"a": "eJanuarydcba", "b": "eJanuarydcb", "c": "eJanuarydc", "d": "eJanuaryd", "e": "eJanuary", "jan": "January"}
+	if !reflect.DeepEqual(expected, vars) {
+		t.Errorf("Expecting %v but got %v", expected, vars)
+	}
+
+	bib, err = Parse(strings.NewReader(`@string{a = "x" # B}
+@string{b = a}
+@string{c = "c"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bib.ResolveStrings()
+	var cyclic *CyclicMacroError
+	if !errors.As(err, &cyclic) {
+		t.Fatalf("Expecting CyclicMacroError but got %v", err)
+	}
+	if want, got := []string{"a", "b", "a"}, cyclic.Cycle; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting cycle %v but got %v", want, got)
+	}
+}
+
+func TestCompositeResolve(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{conf = {ICSE}}
+@inproceedings{abcd, booktitle = "Proc. " # conf # " 2021"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp, ok := bib.Entries[0].Fields["booktitle"].(*BibComposite)
+	if !ok {
+		t.Fatalf("Expecting a composite but got %T", bib.Entries[0].Fields["booktitle"])
+	}
+	if want, got := 3, len(*comp); want != got {
+		t.Fatalf("Expecting %d operands but got %d", want, got)
+	}
+	if v, ok := (*comp)[1].(*BibVar); !ok || v.Key != "conf" {
+		t.Errorf("Expecting operand 1 to be var conf but got %#v", (*comp)[1])
+	}
+	for _, i := range []int{0, 2} {
+		if _, ok := (*comp)[i].(BibConst); !ok {
+			t.Errorf("Expecting operand %d to be a constant but got %#v", i, (*comp)[i])
+		}
+	}
+	if want, got := "Proc. ICSE 2021", comp.Resolve(nil); want != got {
+		t.Errorf("Expecting %q but got %q", want, got)
+	}
+	if want, got := "Proc. FSE 2021", comp.Resolve(map[string]string{"conf": "FSE"}); want != got {
+		t.Errorf("Expecting %q but got %q", want, got)
+	}
+}
+
+// Tests that string vars are written out in the order they are defined.
+func TestStringVarOrder(t *testing.T) {
+	const input = `@string{zeta = {Z}}
+@string{alpha = {A}}
+@string{Mid = zeta # alpha}
+@string{ZETA = {Z2}}
+`
+	for i := 0; i < 10; i++ { // Map iteration order varies between runs.
+		bib, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bib.AddStringVar("new", NewBibConst("N"))
+		expected := `@string{ZETA = {Z2}}
+@string{alpha = {A}}
+@string{Mid = zeta # alpha}
+@string{new = {N}}
+`
+		if s := bib.RawString(); s != expected {
+			t.Fatalf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+		}
+	}
+}
+
+// Tests that fingerprints ignore field order and delimiters but not values.
+func TestEntryEqual(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{j = "J. Test"}
+@article{key, title = {A Title}, journal = j, year = 2020}
+@ARTICLE{Key, Year = {2020}, journal = "J. Test", title = "A Title"}
+@article{key, title = {A Title}, journal = j, year = 2021}
+@article{key, title = {A Title}, journal = j}
+@misc{key, title = {A Title}, journal = j, year = 2020}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := bib.Entries
+	if !e[0].Equal(e[1]) || !e[1].Equal(e[0]) {
+		t.Errorf("Expecting entries differing by field order and delimiters to be equal:\n%s\n%s", e[0].RawString(), e[1].RawString())
+	}
+	for _, other := range e[2:] {
+		if e[0].Equal(other) || other.Equal(e[0]) {
+			t.Errorf("Expecting entries to differ:\n%s\n%s", e[0].RawString(), other.RawString())
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{abcd,
+  title = {Hello World},
+  year = 2020,
+}
+@ARTICLE{abcd,
+  year = "2020",
+  title = "Hello World",
+}
+@article{abcd,
+  title = {Hello World},
+  year = 2021,
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := bib.Entries[0].Fingerprint(), bib.Entries[1].Fingerprint(), bib.Entries[2].Fingerprint()
+	if a != b {
+		t.Errorf("Expecting equal fingerprints but got %s and %s", a, b)
+	}
+	if a == c {
+		t.Errorf("Expecting different fingerprints for different values but got %s", a)
+	}
+}
+
+// Tests that verbatim fields are compared as they are.
+func TestFingerprintVerbatim(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, url = {http://x/a--b}, file = {C:\u\x.pdf}}
+@misc{a, url = {http://x/a–b}, file = {C:\u\x.pdf}}
+@misc{a, url = {http://x/a--b}, file = {C:\U\x.pdf}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := bib.Entries[0].Fingerprint(), bib.Entries[1].Fingerprint(), bib.Entries[2].Fingerprint()
+	if a == b || a == c {
+		t.Errorf("Expecting different fingerprints for different urls and files but got %s, %s and %s", a, b, c)
+	}
+}
+
+// Tests that entries differing only in how accents are written are the same.
+func TestFingerprintAccents(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{mueller2020, author = {M{\"u}ller, J{\"o}rg}, title = {{\'E}tude}}
+@article{mueller2020, author = "Müller, Jörg", title = "Étude"}
+@article{mueller2020a, author = {M\"{u}ller, J\"{o}rg}, title = {{\'{E}}tude}, year = 2020}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := bib.Entries[0].Fingerprint(), bib.Entries[1].Fingerprint(); a != b {
+		t.Errorf("Expecting equal fingerprints but got %s and %s", a, b)
+	}
+	expected := [][2]string{{"mueller2020", "mueller2020a"}, {"mueller2020", "mueller2020"}, {"mueller2020", "mueller2020a"}}
+	if got := bib.FindSubsumed(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting %v but got %v", expected, got)
+	}
+}
+
+// Tests that the delimiter of each parsed field is recorded.
+func TestDelimiter(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{ieee = {IEEE}}
+@article{abcd,
+  title = {{Protected} Title},
+  author = "Doe, John",
+  year = 2020,
+  journal = ieee,
+  note = "See " # ieee,
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	for field, expected := range map[string]DelimiterKind{
+		"title":   DelimBraces,
+		"author":  DelimQuotes,
+		"year":    DelimBare,
+		"journal": DelimMacro,
+		"note":    DelimConcat,
+	} {
+		if want, got := expected, entry.Delimiter(field); want != got {
+			t.Errorf("Expecting %s delimiter %s but got %s", field, want, got)
+		}
+	}
+
+	entry.AddField("author", NewBibConst("Doe, Jane"))
+	if want, got := DelimBraces, entry.Delimiter("author"); want != got {
+		t.Errorf("Expecting delimiter %s after AddField but got %s", want, got)
+	}
+}
+
+// Tests that the last entry is parsed when the input has no trailing newline.
+func TestNoTrailingNewline(t *testing.T) {
+	for _, input := range []string{
+		`@article{abcd, title = {Hello}}`,
+		`@article{abcd, title = "Hello"}`,
+		`@article{abcd, title = {Hello},}`,
+		`@article(abcd, title = {Hello})`,
+		`@string{x = {Hello}} @article{abcd, title = x}`,
+	} {
+		bib, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("Cannot parse %q: %v", input, err)
+			continue
+		}
+		if want, got := 1, len(bib.Entries); want != got {
+			t.Errorf("Expecting %d entries in %q but got %d", want, input, got)
+			continue
+		}
+		if want, got := "Hello", bib.Entries[0].Fields["title"].String(); want != got {
+			t.Errorf("Expecting title %q in %q but got %q", want, input, got)
+		}
+	}
+}
+
+// Tests rewriting values through a function.
+func TestRewriteValues(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{abcd,
+  title = {Hello World},
+  author = {Doe, John},
+  month = jan,
+}
+@book{efgh,
+  title = {Another Title},
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib.RewriteValues(func(entryType, key, field, value string) string {
+		if field == "title" {
+			return strings.ToUpper(value)
+		}
+		return value
+	})
+	if want, got := "HELLO WORLD", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := "ANOTHER TITLE", bib.Entries[1].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := "Doe, John", bib.Entries[0].Fields["author"].String(); want != got {
+		t.Errorf("Expecting author %q but got %q", want, got)
+	}
+	if want, got := "jan", bib.Entries[0].Fields["month"].RawString(); want != got {
+		t.Errorf("Expecting month to still reference %q but got %q", want, got)
+	}
+}
+
+// Tests factoring repeated values out into string vars.
+func TestExtractStrings(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{jomlr = {Something else}}
+@article{a, journal = {Journal of Machine Learning Research}}
+@article{b, journal = {Journal of Machine Learning Research}}
+@article{c, journal = {Journal of Machine Learning Research}}
+@article{d, journal = {Rare Journal}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted := bib.ExtractStrings("journal", 3)
+	if want, got := 1, len(extracted); want != got {
+		t.Fatalf("Expecting %d string vars but got %d: %v", want, got, extracted)
+	}
+	if want, got := "Journal of Machine Learning Research", extracted["jomlr2"]; want != got {
+		t.Errorf("Expecting jomlr2 to be %q but got %q (%v)", want, got, extracted)
+	}
+	for _, entry := range bib.Entries[:3] {
+		if want, got := "jomlr2", entry.Fields["journal"].RawString(); want != got {
+			t.Errorf("Expecting %s journal to reference %q but got %q", entry.CiteName, want, got)
+		}
+		if want, got := "Journal of Machine Learning Research", entry.Fields["journal"].String(); want != got {
+			t.Errorf("Expecting %s journal %q but got %q", entry.CiteName, want, got)
+		}
+	}
+	if want, got := "{Rare Journal}", bib.Entries[3].Fields["journal"].RawString(); want != got {
+		t.Errorf("Expecting journal %q but got %q", want, got)
+	}
+}
+
+// Tests grouping entries by type.
+func TestEntriesByType(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{x = {X}}
+@preamble{"\\newcommand{\\noop}[1]{}"}
+@article{a1, title = x}
+@Book{b1, title = {B1}}
+@comment{"ignored"}
+@ARTICLE{a2, title = {A2}}
+@book{b2, title = {B2}}
+@article{a3, title = {A3}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := bib.EntriesByType()
+	expected := map[string][]string{
+		"article": {"a1", "a2", "a3"},
+		"book":    {"b1", "b2"},
+	}
+	if want, got := len(expected), len(groups); want != got {
+		t.Errorf("Expecting %d groups but got %d: %v", want, got, groups)
+	}
+	for entryType, names := range expected {
+		if want, got := len(names), len(groups[entryType]); want != got {
+			t.Errorf("Expecting %d %s entries but got %d", want, entryType, got)
+			continue
+		}
+		for i, entry := range groups[entryType] {
+			if want, got := names[i], entry.CiteName; want != got {
+				t.Errorf("Expecting %s entry %d to be %s but got %s", entryType, i, want, got)
+			}
+		}
+	}
+}
+
+func TestFieldCoverage(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {A}, DOI = {10.1/a}, abstract = {}}
+@book{b, Title = {B}, year = 2020}
+@article{c, title = {C}, doi = {10.1/c}, year = 2021}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{"title": 3, "doi": 2, "year": 2}
+	if got := bib.FieldCoverage(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting coverage %v but got %v", expected, got)
+	}
+
+	bib = NewBibTex()
+	for i, name := range []string{"Straße", "STRASSE"} {
+		entry := NewBibEntry("misc", fmt.Sprint(i))
+		entry.AddField(name, NewBibConst("x"))
+		bib.AddEntry(entry)
+	}
+	expected = map[string]int{"strasse": 2}
+	if got := bib.FieldCoverage(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting case-folded coverage %v but got %v", expected, got)
+	}
+}
+
+func TestDominantType(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@book{b1, title = {B1}}
+@article{a1, title = {A1}}
+@Article{a2, title = {A2}}
+@misc{m1, title = {M1}}
+@ARTICLE{a3, title = {A3}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "article", bib.DominantType(); want != got {
+		t.Errorf("Expecting dominant type %q but got %q", want, got)
+	}
+
+	bib, err = Parse(strings.NewReader(`@misc{m1, note = {1}} @book{b1, note = {2}} @book{b2, note = {3}} @misc{m2, note = {4}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "misc", bib.DominantType(); want != got {
+		t.Errorf("Expecting tie to go to %q but got %q", want, got)
+	}
+	if want, got := "", NewBibTex().DominantType(); want != got {
+		t.Errorf("Expecting no dominant type but got %q", got)
+	}
+}
+
+func TestUniquePrefixes(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{smith2020, title = {A}}
+@article{smith2021, title = {B}}
+@article{jones2020, title = {C}}
+@article{Smithson, title = {D}}
+@article{doe, title = {E}}
+@article{doe99, title = {F}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"smith2020": "smith2020",
+		"smith2021": "smith2021",
+		"jones2020": "j",
+		"Smithson":  "Smiths",
+		"doe":       "doe",
+		"doe99":     "doe9",
+	}
+	if got := bib.UniquePrefixes(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting prefixes %v but got %v", expected, got)
+	}
+}
+
+func TestYears(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, year = {2020}}
+@article{b, year = 2018}
+@article{c, year = {{2021}}}
+@online{d, date = {2019-05-01/2019-05-03}}
+@article{e, year = {2020a}}
+@misc{f, year = {n.d.}}
+@misc{g, title = {No year}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{2018, 2019, 2020, 2021}
+	if got := bib.Years(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting years %v but got %v", expected, got)
+	}
+}
+
 // Test that the parser accepts all valid bibtex files in the example/ dir.
 func TestParser(t *testing.T) {
 	examples, err := filepath.Glob("example/*.bib")
@@ -127,7 +687,7 @@ func TestPrettyStringRoundTrip(t *testing.T) {
 		}
 
 		// Parse into BibTeX.
-		bib, err = Parse(bytes.NewReader(b))
+		bib, err := Parse(bytes.NewReader(b))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -145,6 +705,63 @@ func TestPrettyStringRoundTrip(t *testing.T) {
 	}
 }
 
+// Tests that backslashes in values (e.g. file paths, urls) are written out
+// verbatim by PrettyString.
+func TestPrettyStringVerbatim(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{abcd,
+  title = {The {\"o}ffice},
+  file = {C:\refs\x.pdf},
+  url = {http://example.com/a\_b},
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := bib.PrettyString()
+	for _, expected := range []string{`"C:\refs\x.pdf"`, `"http://example.com/a\_b"`, `{The {\"o}ffice}`} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expecting %s in output:\n%s", expected, s)
+		}
+	}
+	bib2, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEntryListsEqual(t, bib.Entries, bib2.Entries)
+}
+
+// Tests that math, including = and braces, is kept byte for byte.
+func TestMathRoundTrip(t *testing.T) {
+	expected := map[string]string{
+		"title":    `Mass--energy $E = mc^{2}$ and $$\int_0^1 x\,dx = \frac{1}{2}$$`,
+		"subtitle": `Where $a=b$ and $\{x\}$`,
+	}
+	bib, err := Parse(strings.NewReader(`@article{abcd,
+  title = {Mass--energy $E = mc^{2}$ and $$\int_0^1 x\,dx = \frac{1}{2}$$},
+  subtitle = "Where $a=b$ and $\{x\}$",
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, output := range map[string]func(*BibTex) string{
+		"PrettyString": (*BibTex).PrettyString,
+		"RawString":    (*BibTex).RawString,
+	} {
+		bib2, err := Parse(strings.NewReader(output(bib)))
+		if err != nil {
+			t.Fatalf("Cannot parse %s output: %v", name, err)
+		}
+		for field, want := range expected {
+			for _, b := range []*BibTex{bib, bib2} {
+				if got := b.Entries[0].Fields[field].String(); want != got {
+					t.Errorf("%s: expecting %s %q but got %q", name, field, want, got)
+				}
+			}
+		}
+	}
+}
+
 func AssertEntryListsEqual(t *testing.T, a, b []*BibEntry) {
 	t.Helper()
 
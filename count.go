@@ -0,0 +1,95 @@
+package bibtex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// CountEntries counts the entries in a BibTeX file without parsing them. It
+// only matches the braces (or parentheses) of each top-level @type{...}
+// block, so it is much cheaper than Parse. @string, @preamble and @comment
+// blocks are not counted.
+func CountEntries(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+	n := 0
+	for {
+		if err := skipTo(br, '@'); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		typ, open, err := readBlockStart(br)
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if open == 0 { // Not a block, e.g. a stray @ in a comment.
+			continue
+		}
+		if err := skipBlock(br, open); err == io.EOF {
+			return n, fmt.Errorf("%w: unterminated @%s", io.ErrUnexpectedEOF, typ)
+		} else if err != nil {
+			return n, err
+		}
+		switch foldKey(typ) {
+		case "string", "preamble", "comment":
+		default:
+			n++
+		}
+	}
+}
+
+// skipTo reads from br up to and including the next delim.
+func skipTo(br *bufio.Reader, delim byte) error {
+	_, err := br.ReadSlice(delim)
+	for err == bufio.ErrBufferFull {
+		_, err = br.ReadSlice(delim)
+	}
+	return err
+}
+
+// readBlockStart reads the type and opening brace or parenthesis of a block
+// following an @. It returns a zero open rune if no block follows.
+func readBlockStart(br *bufio.Reader) (typ string, open rune, err error) {
+	var buf strings.Builder
+	for {
+		ch, _, err := br.ReadRune()
+		if err != nil {
+			return buf.String(), 0, err
+		}
+		switch {
+		case isAlphanum(ch) || isBareSymbol(ch):
+			buf.WriteRune(ch)
+		case unicode.IsSpace(ch):
+		case ch == '{' || ch == '(':
+			return buf.String(), ch, nil
+		default:
+			return buf.String(), 0, br.UnreadRune()
+		}
+	}
+}
+
+// skipBlock reads up to and including the end of a block opened by open.
+func skipBlock(br *bufio.Reader, open rune) error {
+	depth := 0
+	for {
+		ch, _, err := br.ReadRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case ch == '{':
+			depth++
+		case ch == '}' && depth == 0 && open == '{':
+			return nil
+		case ch == '}':
+			depth--
+		case ch == ')' && depth == 0 && open == '(':
+			return nil
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package bibtex
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCountEntries(t *testing.T) {
+	const input = `This text is ignored, as is an email@example.com address.
+@string{ieee = "IEEE"}
+@preamble{"\newcommand{\noop}[1]{}"}
+@comment{skipped @article{not, counted}}
+@Article{a,
+  title = {Nested {braces} and a @ sign},
+}
+@book(b, title = {Parentheses)})
+@misc{c,}
+`
+	n, err := CountEntries(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 3, n; want != got {
+		t.Errorf("Expecting %d entries but got %d", want, got)
+	}
+	bib, err := Parse(strings.NewReader(input[strings.Index(input, "@Article"):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := len(bib.Entries), n; want != got {
+		t.Errorf("Expecting %d entries as parsed but got %d", want, got)
+	}
+
+	if _, err := CountEntries(strings.NewReader(`@misc{a, title = {x}`)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expecting unexpected EOF but got %v", err)
+	}
+}
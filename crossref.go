@@ -0,0 +1,299 @@
+package bibtex
+
+import "fmt"
+
+// ApplyModifies applies the @modify pseudo-entries in bib and removes them.
+// An entry @modify{key, field = value} sets field of the entry with cite name
+// key (case-insensitive) to value, replacing any existing value. Modifies are
+// applied in order; the target must be a regular entry in bib. If any target
+// is missing, bib is left unchanged.
+func (bib *BibTex) ApplyModifies() error {
+	index := make(map[string]*BibEntry, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if !isModify(entry) {
+			if _, ok := index[foldKey(entry.CiteName)]; !ok {
+				index[foldKey(entry.CiteName)] = entry
+			}
+		}
+	}
+	for _, entry := range bib.Entries {
+		if !isModify(entry) {
+			continue
+		}
+		if _, ok := index[foldKey(entry.CiteName)]; !ok {
+			return fmt.Errorf("@modify: %w: %s", ErrUnknownCiteKey, entry.CiteName)
+		}
+	}
+
+	entries := make([]*BibEntry, 0, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if !isModify(entry) {
+			entries = append(entries, entry)
+			continue
+		}
+		target := index[foldKey(entry.CiteName)]
+		for _, name := range entry.fieldNames() {
+			target.AddField(name, entry.Fields[name])
+		}
+	}
+	bib.Entries = entries
+	return nil
+}
+
+func isModify(entry *BibEntry) bool {
+	return foldKey(entry.Type) == "modify"
+}
+
+// CheckCrossrefOrder reports each entry that appears after the entry it
+// crossrefs. Classic BibTeX only inherits fields from a parent defined after
+// its children, so such entries silently lose the cross-referenced data.
+func (bib *BibTex) CheckCrossrefOrder() []error {
+	var errs []error
+	seen := make(map[string]bool, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if parent, ok := entry.field("crossref"); ok && seen[foldKey(parent.String())] {
+			errs = append(errs, fmt.Errorf("%w: %s appears after %s", ErrCrossrefOrder, entry.CiteName, parent.String()))
+		}
+		seen[foldKey(entry.CiteName)] = true
+	}
+	return errs
+}
+
+// CrossrefInherit controls which fields ResolveCrossrefs copies from a parent
+// entry to the entries that crossref it.
+type CrossrefInherit struct {
+	// NoInherit lists the fields that are never inherited.
+	NoInherit []string
+
+	// Rules rename the inherited fields for some combinations of parent and
+	// child entry types. The first matching rule is used.
+	Rules []CrossrefRule
+}
+
+// CrossrefRule renames the fields a child of one of Children types inherits
+// from a parent of one of Parents types. Fields maps a parent field to the
+// child field it is inherited as, or to "" if it is not inherited. Fields
+// not in the map are inherited under the same name.
+type CrossrefRule struct {
+	Parents  []string
+	Children []string
+	Fields   map[string]string
+}
+
+// bookTitleFields renames the title of a parent book to the book title of the
+// parts it contains.
+var bookTitleFields = map[string]string{
+	"title":          "booktitle",
+	"subtitle":       "booksubtitle",
+	"titleaddon":     "booktitleaddon",
+	"shorttitle":     "",
+	"sorttitle":      "",
+	"indextitle":     "",
+	"indexsorttitle": "",
+}
+
+// DefaultCrossrefInherit returns the inheritance rules of BibLaTeX.
+func DefaultCrossrefInherit() *CrossrefInherit {
+	return &CrossrefInherit{
+		NoInherit: []string{
+			"ids", "crossref", "xref", "entryset", "entrysubtype", "execute",
+			"label", "options", "presort", "related", "relatedoptions",
+			"relatedstring", "relatedtype", "shorthand", "shorthandintro",
+			"sortkey",
+		},
+		Rules: []CrossrefRule{
+			{
+				Parents:  []string{"mvbook", "mvcollection", "mvproceedings", "mvreference"},
+				Children: nil, // Any.
+				Fields: map[string]string{
+					"title":          "maintitle",
+					"subtitle":       "mainsubtitle",
+					"titleaddon":     "maintitleaddon",
+					"shorttitle":     "",
+					"sorttitle":      "",
+					"indextitle":     "",
+					"indexsorttitle": "",
+				},
+			},
+			{
+				Parents:  []string{"book"},
+				Children: []string{"inbook", "bookinbook", "suppbook"},
+				Fields: map[string]string{
+					"author":         "bookauthor",
+					"title":          "booktitle",
+					"subtitle":       "booksubtitle",
+					"titleaddon":     "booktitleaddon",
+					"shorttitle":     "",
+					"sorttitle":      "",
+					"indextitle":     "",
+					"indexsorttitle": "",
+				},
+			},
+			{
+				Parents:  []string{"collection", "reference"},
+				Children: []string{"incollection", "inreference", "suppcollection"},
+				Fields:   bookTitleFields,
+			},
+			{
+				Parents:  []string{"proceedings"},
+				Children: []string{"inproceedings"},
+				Fields:   bookTitleFields,
+			},
+			{
+				Parents:  []string{"periodical"},
+				Children: []string{"article", "suppperiodical"},
+				Fields: map[string]string{
+					"title":          "journaltitle",
+					"subtitle":       "journalsubtitle",
+					"shorttitle":     "",
+					"sorttitle":      "",
+					"indextitle":     "",
+					"indexsorttitle": "",
+				},
+			},
+		},
+	}
+}
+
+// rule returns the first rule for a child of type child inheriting from a
+// parent of type parent, or nil.
+func (inherit *CrossrefInherit) rule(parent, child string) *CrossrefRule {
+	matches := func(types []string, typ string) bool {
+		if types == nil {
+			return true
+		}
+		for _, t := range types {
+			if foldKey(t) == foldKey(typ) {
+				return true
+			}
+		}
+		return false
+	}
+	for i, rule := range inherit.Rules {
+		if matches(rule.Parents, parent) && matches(rule.Children, child) {
+			return &inherit.Rules[i]
+		}
+	}
+	return nil
+}
+
+// ResolveCrossrefs copies the fields of each crossref parent into the entries
+// that reference it, following inherit (or DefaultCrossrefInherit if nil).
+// Fields already set in a child are kept as they are. Only one level of
+// crossref is resolved, as in BibTeX: a child inherits the fields of its
+// parent, not those the parent inherits itself. If any crossref or xref is
+// unknown, no entry is changed.
+//
+// An xref field only links an entry to its parent, as in BibLaTeX: the parent
+// must exist, but no fields are inherited from it.
+func (bib *BibTex) ResolveCrossrefs(inherit *CrossrefInherit) error {
+	if inherit == nil {
+		inherit = DefaultCrossrefInherit()
+	}
+	noInherit := make(map[string]bool, len(inherit.NoInherit))
+	for _, name := range inherit.NoInherit {
+		noInherit[foldKey(name)] = true
+	}
+	index := make(map[string]*BibEntry, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if _, ok := index[foldKey(entry.CiteName)]; !ok {
+			index[foldKey(entry.CiteName)] = entry
+		}
+	}
+
+	// Check every reference and collect the inherited fields before changing
+	// any entry, so that only the parents' own fields are inherited.
+	type inherited struct {
+		entry *BibEntry
+		name  string
+		val   BibString
+	}
+	var pending []inherited
+	for _, entry := range bib.Entries {
+		if ref, ok := entry.field("xref"); ok {
+			if _, ok := index[foldKey(ref.String())]; !ok {
+				return fmt.Errorf("%s: xref %w: %s", entry.CiteName, ErrUnknownCiteKey, ref.String())
+			}
+		}
+		ref, ok := entry.field("crossref")
+		if !ok {
+			continue
+		}
+		parent, ok := index[foldKey(ref.String())]
+		if !ok {
+			return fmt.Errorf("%s: crossref %w: %s", entry.CiteName, ErrUnknownCiteKey, ref.String())
+		}
+		set := make(map[string]bool, len(entry.Fields))
+		for name := range entry.Fields {
+			set[foldKey(name)] = true
+		}
+		rule := inherit.rule(parent.Type, entry.Type)
+		for _, name := range parent.fieldNames() {
+			val := parent.Fields[name]
+			if noInherit[foldKey(name)] {
+				continue
+			}
+			if rule != nil {
+				if renamed, ok := rule.Fields[foldKey(name)]; ok {
+					name = renamed
+				}
+			}
+			if name != "" && !set[foldKey(name)] {
+				pending = append(pending, inherited{entry, name, val})
+			}
+		}
+	}
+	for _, p := range pending {
+		p.entry.AddField(p.name, p.val)
+	}
+	return nil
+}
+
+// crossrefFields are the fields that reference other entries by cite name,
+// see CrossrefGraph.
+var crossrefFields = []string{"crossref", "xref", "xdata", "related", "ids"}
+
+// isCrossrefField returns true if the named field is one of crossrefFields.
+func isCrossrefField(name string) bool {
+	for _, field := range crossrefFields {
+		if foldKey(name) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// CrossrefGraph returns the references between the entries of bib, mapping
+// the cite name of each entry to the cite names in its crossref, xref, xdata,
+// related and ids fields (in that order). Entries without references map to
+// nil. References to entries not in bib are included as they are.
+func (bib *BibTex) CrossrefGraph() map[string][]string {
+	graph := make(map[string][]string, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		var refs []string
+		for _, field := range crossrefFields {
+			if val, ok := entry.field(field); ok {
+				refs = append(refs, splitTopLevel(val.String(), ",")...)
+			}
+		}
+		graph[entry.CiteName] = append(graph[entry.CiteName], refs...)
+	}
+	return graph
+}
+
+// ReferencedBy returns the cite names of the entries of bib that reference
+// key (case-insensitively) in one of the fields of CrossrefGraph, in entry
+// order, e.g. to check that an entry can be removed.
+func (bib *BibTex) ReferencedBy(key string) []string {
+	graph := bib.CrossrefGraph()
+	var names []string
+	for _, entry := range bib.Entries {
+		for _, ref := range graph[entry.CiteName] {
+			if foldKey(ref) == foldKey(key) {
+				names = append(names, entry.CiteName)
+				break
+			}
+		}
+	}
+	return names
+}
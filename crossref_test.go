@@ -0,0 +1,284 @@
+package bibtex
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyModifies(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{target,
+  title = {Old title},
+  year = 2019,
+}
+@modify{Target, title = {New title}, note = {Added}}
+@article{other, title = {Other}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ApplyModifies(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	target := bib.Entries[0]
+	for field, expected := range map[string]string{
+		"title": "New title",
+		"note":  "Added",
+		"year":  "2019",
+	} {
+		if want, got := expected, target.Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+
+	bib, err = Parse(strings.NewReader(`@modify{missing, title = {New title}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ApplyModifies(); !errors.Is(err, ErrUnknownCiteKey) {
+		t.Errorf("Expecting unknown cite key error but got %v", err)
+	}
+}
+
+func TestApplyModifiesMissingTarget(t *testing.T) {
+	const input = `@article{a, title = {A}}
+@book{b, title = {B}}
+@modify{a, title = {New A}}
+@modify{zzz, title = {New Z}}
+@modify{b, title = {New B}}
+`
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := bib.ValidateKeys(); len(errs) != 0 {
+		t.Errorf("Expecting @modify keys not to be duplicates but got %v", errs)
+	}
+	if groups := bib.EntriesByType(); len(groups) != 2 || groups["modify"] != nil {
+		t.Errorf("Expecting @modify entries not to be grouped but got %v", groups)
+	}
+	if err := bib.ApplyModifies(); !errors.Is(err, ErrUnknownCiteKey) || !strings.Contains(err.Error(), "zzz") {
+		t.Errorf("Expecting unknown cite key error for zzz but got %v", err)
+	}
+	if want, got := 5, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries to be left alone but got %d", want, got)
+	}
+	for i, expected := range []string{"a", "b", "a", "zzz", "b"} {
+		if want, got := expected, bib.Entries[i].CiteName; want != got {
+			t.Errorf("Expecting entry %d to be %s but got %s", i, want, got)
+		}
+	}
+	if want, got := "A", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q to be unchanged but got %q", want, got)
+	}
+}
+
+func TestApplyModifiesFieldOrder(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {A}}
+@modify{a, note = {N}, year = 2020, pages = {1--2}, doi = {10.1000/x}, abstract = {X}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ApplyModifies(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"title", "note", "year", "pages", "doi", "abstract"}
+	if got := bib.Entries[0].fieldNames(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting fields %q but got %q", expected, got)
+	}
+}
+
+func TestCheckCrossrefOrder(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@inproceedings{good, crossref = {Conf}, title = {Good}}
+@proceedings{conf, title = {Conference}}
+@inproceedings{bad, crossref = {conf}, title = {Bad}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := bib.CheckCrossrefOrder()
+	if want, got := 1, len(errs); want != got {
+		t.Fatalf("Expecting %d error but got %d: %v", want, got, errs)
+	}
+	if !errors.Is(errs[0], ErrCrossrefOrder) || !strings.Contains(errs[0].Error(), "bad") {
+		t.Errorf("Expecting crossref order error for bad but got %v", errs[0])
+	}
+}
+
+func TestResolveCrossrefs(t *testing.T) {
+	const input = `@inproceedings{paper, crossref = {conf}, title = {A Paper}, pages = {1--10}}
+@proceedings{conf,
+  title = {Proceedings of the Conference},
+  shorttitle = {Conf},
+  editor = {Doe, Jane},
+  year = 2021,
+}
+`
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(nil); err != nil {
+		t.Fatal(err)
+	}
+	paper := bib.Entries[0]
+	for field, expected := range map[string]string{
+		"title":     "A Paper",
+		"booktitle": "Proceedings of the Conference",
+		"editor":    "Doe, Jane",
+		"year":      "2021",
+		"pages":     "1--10",
+		"crossref":  "conf",
+	} {
+		if got, ok := paper.Fields[field]; !ok || got.String() != expected {
+			t.Errorf("Expecting %s %q but got %v", field, expected, got)
+		}
+	}
+	if got, ok := paper.Fields["shorttitle"]; ok {
+		t.Errorf("Expecting shorttitle not to be inherited but got %q", got)
+	}
+	order := []string{"crossref", "title", "pages", "booktitle", "editor", "year"}
+	if got := paper.fieldNames(); !reflect.DeepEqual(order, got) {
+		t.Errorf("Expecting inherited fields in parent order %q but got %q", order, got)
+	}
+
+	// Custom rules: inherit everything but the year, without renaming.
+	bib, err = Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(&CrossrefInherit{NoInherit: []string{"year"}}); err != nil {
+		t.Fatal(err)
+	}
+	paper = bib.Entries[0]
+	if _, ok := paper.Fields["booktitle"]; ok {
+		t.Errorf("Expecting no booktitle without rename rules")
+	}
+	if _, ok := paper.Fields["year"]; ok {
+		t.Errorf("Expecting year not to be inherited")
+	}
+	if want, got := "Conf", paper.Fields["shorttitle"]; got == nil || got.String() != want {
+		t.Errorf("Expecting shorttitle %q but got %v", want, got)
+	}
+	if want, got := "A Paper", paper.Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+
+	bib, err = Parse(strings.NewReader(`@inproceedings{paper, crossref = {missing}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(nil); !errors.Is(err, ErrUnknownCiteKey) {
+		t.Errorf("Expecting unknown cite key error but got %v", err)
+	}
+}
+
+func TestResolveXref(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@inbook{chapter, xref = {book}, title = {A Chapter}}
+@book{book, title = {The Book}, publisher = {P}, year = 2020}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(nil); err != nil {
+		t.Fatal(err)
+	}
+	chapter := bib.Entries[0]
+	if want, got := 2, len(chapter.Fields); want != got {
+		t.Errorf("Expecting %d fields without inheritance but got %d: %v", want, got, chapter.Fields)
+	}
+	if want, got := []string{"book"}, bib.CrossrefGraph()["chapter"]; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting xref in graph as %v but got %v", want, got)
+	}
+
+	bib, err = Parse(strings.NewReader(`@inbook{chapter, xref = {missing}, title = {A Chapter}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(nil); !errors.Is(err, ErrUnknownCiteKey) {
+		t.Errorf("Expecting unknown xref to be an error but got: %v", err)
+	}
+}
+
+// Tests that only one level of crossref is resolved, whatever the entry order,
+// and that nothing is changed if a parent is unknown.
+func TestResolveCrossrefsOneLevel(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@proceedings{conf, crossref = {series}, title = {Conf}}
+@inproceedings{paper, crossref = {conf}, title = {Paper}}
+@misc{series, publisher = {P}, note = {N}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Pair{{"crossref", "conf"}, {"title", "Paper"}, {"booktitle", "Conf"}}
+	if got := bib.Entries[1].Pairs(false); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting paper fields %v but got %v", expected, got)
+	}
+	if want, got := "P", bib.Entries[0].Fields["publisher"].String(); want != got {
+		t.Errorf("Expecting conf publisher %q but got %q", want, got)
+	}
+
+	bib, err = Parse(strings.NewReader(`@proceedings{conf, title = {Conf}}
+@inproceedings{paper, crossref = {conf}}
+@inproceedings{other, crossref = {missing}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.ResolveCrossrefs(nil); !errors.Is(err, ErrUnknownCiteKey) {
+		t.Errorf("Expecting unknown crossref to be an error but got: %v", err)
+	}
+	if got, ok := bib.Entries[1].Fields["booktitle"]; ok {
+		t.Errorf("Expecting paper to be unchanged but got booktitle %q", got)
+	}
+}
+
+func TestCrossrefGraph(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@inproceedings{paper, crossref = {conf}, xdata = {pubA, pubB}}
+@proceedings{conf, xdata = {pubA}}
+@xdata{pubA, publisher = {A}}
+@article{other, related = {paper,missing}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"paper": {"conf", "pubA", "pubB"},
+		"conf":  {"pubA"},
+		"pubA":  nil,
+		"other": {"paper", "missing"},
+	}
+	if got := bib.CrossrefGraph(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting graph %v but got %v", expected, got)
+	}
+}
+
+func TestReferencedBy(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@inproceedings{paper1, crossref = {Conf}}
+@inproceedings{paper2, crossref = {conf}, xdata = {pubA}}
+@proceedings{conf, xdata = {pubA}}
+@xdata{pubA, publisher = {A}}
+@article{other, related = {paper1}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string][]string{
+		"conf":   {"paper1", "paper2"},
+		"pubA":   {"paper2", "conf"},
+		"paper1": {"other"},
+		"other":  nil,
+	} {
+		if got := bib.ReferencedBy(key); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expecting %s to be referenced by %v but got %v", key, expected, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package bibtex
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// nameFields are the fields that hold lists of names, see ParseNames.
+var nameFields = map[string]bool{"author": true, "editor": true, "translator": true}
+
+// WriteCSV writes the entries of bib to w as CSV, one row per entry, with a
+// header row of the column names. The key and type columns hold the cite name
+// and type of the entry, and are followed by the fields named in columns
+// (compared case-insensitively). Values are decoded from LaTeX, except in
+// verbatim fields such as url, and the names in the author, editor and
+// translator fields are joined by "; ". Missing fields give empty cells.
+func (bib *BibTex) WriteCSV(w io.Writer, columns []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"key", "type"}, columns...)); err != nil {
+		return err
+	}
+	for _, entry := range bib.Entries {
+		fields := make(map[string]string, len(entry.Fields))
+		for name, val := range entry.Fields {
+			fields[foldKey(name)] = val.String()
+		}
+		row := []string{entry.CiteName, entry.Type}
+		for _, column := range columns {
+			row = append(row, csvValue(entry, foldKey(column), fields[foldKey(column)]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue returns the value of the named field of entry as written by
+// WriteCSV.
+func csvValue(entry *BibEntry, name, value string) string {
+	if !nameFields[name] {
+		return entry.decodeField(name, strings.TrimSpace(value))
+	}
+	list := ParseNames(value)
+	names := make([]string, 0, len(list.Names)+1)
+	for _, n := range list.Names {
+		names = append(names, decodeLaTeX(n.String(), true))
+	}
+	if list.HasOthers {
+		names = append(names, "others")
+	}
+	return strings.Join(names, "; ")
+}
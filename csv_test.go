@@ -0,0 +1,48 @@
+package bibtex
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{smith2020,
+  author = {Smith, John and Jane G{\"o}del},
+  Title = {Commas, "Quotes" and {Braces}},
+  year = 2020,
+}
+@book{knuth, author = {Donald E. Knuth and others}, title = {The Art of Computer Programming}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := bib.WriteCSV(&buf, []string{"author", "title", "journal"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `key,type,author,title,journal
+smith2020,article,"Smith, John; Gödel, Jane","Commas, ""Quotes"" and Braces",
+knuth,book,"Knuth, Donald E.; others",The Art of Computer Programming,
+`
+	if want, got := expected, buf.String(); want != got {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestWriteCSVVerbatim(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, title = {a--b}, url = {http://x/a--b}, doi = {10.1000/a--b}, file = {C:\u\x.pdf}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := bib.WriteCSV(&buf, []string{"title", "url", "doi", "file"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `key,type,title,url,doi,file
+a,misc,a–b,http://x/a--b,10.1000/a--b,C:\u\x.pdf
+`
+	if want, got := expected, buf.String(); want != got {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", got, want)
+	}
+}
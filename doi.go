@@ -0,0 +1,71 @@
+package bibtex
+
+import (
+	"regexp"
+	"strings"
+)
+
+// doiPattern matches a DOI, possibly as a doi.org URL or with a doi: prefix.
+var doiPattern = regexp.MustCompile(`(?i)(?:https?://(?:dx\.)?doi\.org/|doi:\s*)?(10\.\d{4,9}/[^\s"<>{}]+)`)
+
+// NormalizeDOI returns the DOI in s, e.g. a doi.org URL or "doi:10.1000/x",
+// in its bare lowercase form "10.1000/x". It returns false if s contains no
+// DOI.
+func NormalizeDOI(s string) (string, bool) {
+	loc := doiPatternIndex(s)
+	if loc == nil {
+		return "", false
+	}
+	return strings.ToLower(s[loc[2]:loc[3]]), true
+}
+
+// doiPatternIndex returns the location of the first DOI in s and of the DOI
+// itself as by FindStringSubmatchIndex, without trailing punctuation.
+func doiPatternIndex(s string) []int {
+	loc := doiPattern.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil
+	}
+	for loc[3] > loc[2] && strings.ContainsRune(".,;:)]", rune(s[loc[3]-1])) {
+		loc[3]--
+	}
+	loc[1] = loc[3]
+	return loc
+}
+
+// PromoteDOIs moves DOIs found in the url or note field of entries into their
+// doi field, normalized with NormalizeDOI. Entries that already have a doi
+// field are left alone. If remove is set, the DOI is also removed from the
+// field it was found in, and the field dropped if nothing else is left.
+func (bib *BibTex) PromoteDOIs(remove bool) {
+	for _, entry := range bib.Entries {
+		if entry.firstField("doi") != "" {
+			continue
+		}
+		for _, field := range []string{"url", "note"} {
+			field, ok := entry.fieldName(field)
+			if !ok {
+				continue
+			}
+			s := entry.Fields[field].String()
+			loc := doiPatternIndex(s)
+			if loc == nil {
+				continue
+			}
+			doi, ok := entry.fieldName("doi")
+			if !ok {
+				doi = "doi"
+			}
+			entry.AddField(doi, NewBibConst(strings.ToLower(s[loc[2]:loc[3]])))
+			if remove {
+				rest := strings.Trim(s[:loc[0]]+s[loc[1]:], " ,;.")
+				if rest == "" {
+					delete(entry.Fields, field)
+				} else {
+					entry.AddField(field, NewBibConst(rest))
+				}
+			}
+			break
+		}
+	}
+}
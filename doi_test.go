@@ -0,0 +1,58 @@
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDOI(t *testing.T) {
+	for input, expected := range map[string]string{
+		"10.1145/3368089.3409741":                 "10.1145/3368089.3409741",
+		"https://doi.org/10.1000/ABC.def":         "10.1000/abc.def",
+		"http://dx.doi.org/10.1000/xyz123":        "10.1000/xyz123",
+		"See doi: 10.1000/x(1)2, retrieved 2020.": "10.1000/x(1)2",
+	} {
+		if got, ok := NormalizeDOI(input); !ok || got != expected {
+			t.Errorf("Expecting DOI %q in %q but got %q", expected, input, got)
+		}
+	}
+	if got, ok := NormalizeDOI("https://example.com/10.1/x"); ok {
+		t.Errorf("Expecting no DOI but got %q", got)
+	}
+}
+
+func TestPromoteDOIs(t *testing.T) {
+	const input = `@article{url, url = {https://doi.org/10.1000/URL}}
+@article{note, note = {Preprint, doi:10.1000/note.}}
+@article{has, doi = {10.1000/kept}, url = {https://doi.org/10.1000/other}}
+@article{none, url = {https://example.com}}
+`
+	for _, remove := range []bool{false, true} {
+		bib, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bib.PromoteDOIs(remove)
+		expected := map[string]map[string]string{
+			"url":  {"doi": "10.1000/url", "url": "https://doi.org/10.1000/URL"},
+			"note": {"doi": "10.1000/note", "note": "Preprint, doi:10.1000/note."},
+			"has":  {"doi": "10.1000/kept", "url": "https://doi.org/10.1000/other"},
+			"none": {"url": "https://example.com"},
+		}
+		if remove {
+			delete(expected["url"], "url")
+			expected["note"]["note"] = "Preprint"
+		}
+		for _, entry := range bib.Entries {
+			fields := expected[entry.CiteName]
+			if want, got := len(fields), len(entry.Fields); want != got {
+				t.Errorf("remove=%t: expecting %d fields in %s but got %v", remove, want, entry.CiteName, entry.Fields)
+			}
+			for field, value := range fields {
+				if got, ok := entry.Fields[field]; !ok || got.String() != value {
+					t.Errorf("remove=%t: expecting %s %s %q but got %v", remove, entry.CiteName, field, value, got)
+				}
+			}
+		}
+	}
+}
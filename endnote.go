@@ -0,0 +1,200 @@
+package bibtex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// endNoteTypes maps EndNote reference types (%0) to BibTeX entry types.
+var endNoteTypes = map[string]string{
+	"journal article":        "article",
+	"magazine article":       "article",
+	"newspaper article":      "article",
+	"book":                   "book",
+	"edited book":            "book",
+	"book section":           "incollection",
+	"conference paper":       "inproceedings",
+	"conference proceedings": "proceedings",
+	"thesis":                 "phdthesis",
+	"report":                 "techreport",
+	"manuscript":             "unpublished",
+	"unpublished work":       "unpublished",
+	"computer program":       "misc",
+	"web page":               "misc",
+	"electronic source":      "misc",
+	"generic":                "misc",
+	"government document":    "techreport",
+}
+
+// endNoteFields maps EndNote tags to BibTeX field names.
+var endNoteFields = map[string]string{
+	"%A": "author",
+	"%E": "editor",
+	"%T": "title",
+	"%J": "journal",
+	"%B": "booktitle",
+	"%D": "year",
+	"%V": "volume",
+	"%N": "number",
+	"%P": "pages",
+	"%I": "publisher",
+	"%C": "address",
+	"%S": "series",
+	"%@": "isbn",
+	"%R": "doi",
+	"%U": "url",
+	"%X": "abstract",
+	"%K": "keywords",
+	"%Z": "note",
+	"%8": "date",
+	"%7": "edition",
+}
+
+// endNoteSeparators is the separator used to join a repeated tag.
+var endNoteSeparators = map[string]string{
+	"%A": " and ",
+	"%E": " and ",
+	"%K": ", ",
+}
+
+// ParseEndNote parses a file in the EndNote tagged (refer) format, where each
+// line of a record is a tag such as %A (author) or %T (title) followed by its
+// value, and records are separated by blank lines.
+//
+// Repeated %A and %E tags are joined with " and ". Lines not starting with
+// a tag continue the value of the previous tag. The cite name is taken from
+// the %F (label) tag if present, otherwise it is generated from the first
+// author's last name and the year, with a suffix (a, b, ...) if that name is
+// already taken, e.g. smith2020, smith2020a.
+func ParseEndNote(r io.Reader) (*BibTex, error) {
+	bib := NewBibTex()
+	rec := newEndNoteRecord()
+	lastTag := ""
+	used := make(map[string]bool) // Folded cite names of the records so far.
+	flush := func() {
+		if !rec.empty() {
+			entry := rec.entry(len(bib.Entries)+1, used)
+			used[foldKey(entry.CiteName)] = true
+			bib.AddEntry(entry)
+		}
+		rec, lastTag = newEndNoteRecord(), ""
+	}
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimRightFunc(sc.Text(), unicode.IsSpace)
+		switch {
+		case text == "":
+			flush()
+		case strings.HasPrefix(text, "%"):
+			if len(text) < 2 || (len(text) > 2 && text[2] != ' ') {
+				return nil, fmt.Errorf("line %d: %w: %q", line, ErrBadEndNoteTag, text)
+			}
+			lastTag = text[:2]
+			rec.add(lastTag, strings.TrimSpace(text[2:]))
+		case lastTag != "":
+			rec.extend(lastTag, strings.TrimSpace(text))
+		default:
+			return nil, fmt.Errorf("line %d: %w: %q", line, ErrBadEndNoteTag, text)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return bib, nil
+}
+
+// endNoteRecord holds the tag values of a single EndNote record.
+type endNoteRecord struct {
+	tags   []string            // Tags in order of first appearance.
+	values map[string][]string // Values of each tag.
+}
+
+func newEndNoteRecord() *endNoteRecord {
+	return &endNoteRecord{values: make(map[string][]string)}
+}
+
+func (rec *endNoteRecord) empty() bool {
+	return len(rec.tags) == 0
+}
+
+func (rec *endNoteRecord) add(tag, value string) {
+	if _, ok := rec.values[tag]; !ok {
+		rec.tags = append(rec.tags, tag)
+	}
+	rec.values[tag] = append(rec.values[tag], value)
+}
+
+// extend appends a continuation line to the last value of tag.
+func (rec *endNoteRecord) extend(tag, value string) {
+	vals := rec.values[tag]
+	vals[len(vals)-1] = strings.TrimSpace(vals[len(vals)-1] + " " + value)
+}
+
+func (rec *endNoteRecord) get(tag string) string {
+	if vals := rec.values[tag]; len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// entry converts the record to a BibEntry, n is the position of the record in
+// the file and is used as a last resort cite name, used holds the folded cite
+// names already taken.
+func (rec *endNoteRecord) entry(n int, used map[string]bool) *BibEntry {
+	entryType, ok := endNoteTypes[strings.ToLower(rec.get("%0"))]
+	if !ok {
+		entryType = "misc"
+	}
+	entry := NewBibEntry(entryType, rec.citeName(n, used))
+	for _, tag := range rec.tags {
+		field, ok := endNoteFields[tag]
+		if !ok {
+			continue
+		}
+		sep, ok := endNoteSeparators[tag]
+		if !ok {
+			sep = " "
+		}
+		entry.AddField(field, NewBibConst(strings.Join(rec.values[tag], sep)))
+	}
+	return entry
+}
+
+func (rec *endNoteRecord) citeName(n int, used map[string]bool) string {
+	if label := rec.get("%F"); label != "" {
+		return label
+	}
+	author := rec.get("%A")
+	if i := strings.Index(author, ","); i >= 0 {
+		author = author[:i]
+	} else if fields := strings.Fields(author); len(fields) > 0 {
+		author = fields[len(fields)-1]
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, author+rec.get("%D"))
+	if name == "" {
+		name = fmt.Sprintf("record%d", n)
+	}
+	for base, i := name, 0; used[foldKey(name)]; i++ {
+		name = base + letterSuffix(i)
+	}
+	return name
+}
+
+// letterSuffix returns the i-th of the suffixes a, b, ..., z, aa, ab, ...
+func letterSuffix(i int) string {
+	var s string
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('a'+(i-1)%26)) + s
+	}
+	return s
+}
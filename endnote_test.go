@@ -0,0 +1,100 @@
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEndNote(t *testing.T) {
+	bib, err := ParseEndNote(strings.NewReader(`%0 Journal Article
+%A Smith, John
+%A Doe, Jane
+%T A very long title that is
+folded over two lines
+%J Journal of Things
+%D 2020
+%V 12
+
+%0 Book
+%F knuth84
+%A Knuth, Donald E.
+%T The TeXbook
+%I Addison-Wesley
+%D 1984
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+
+	article := bib.Entries[0]
+	if want, got := "article", article.Type; want != got {
+		t.Errorf("Expecting type %s but got %s", want, got)
+	}
+	if want, got := "smith2020", article.CiteName; want != got {
+		t.Errorf("Expecting cite name %s but got %s", want, got)
+	}
+	for field, expected := range map[string]string{
+		"author":  "Smith, John and Doe, Jane",
+		"title":   "A very long title that is folded over two lines",
+		"journal": "Journal of Things",
+		"year":    "2020",
+		"volume":  "12",
+	} {
+		if want, got := expected, article.Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+
+	book := bib.Entries[1]
+	if want, got := "book", book.Type; want != got {
+		t.Errorf("Expecting type %s but got %s", want, got)
+	}
+	if want, got := "knuth84", book.CiteName; want != got {
+		t.Errorf("Expecting cite name %s but got %s", want, got)
+	}
+	if want, got := "Addison-Wesley", book.Fields["publisher"].String(); want != got {
+		t.Errorf("Expecting publisher %q but got %q", want, got)
+	}
+}
+
+func TestParseEndNoteUniqueNames(t *testing.T) {
+	bib, err := ParseEndNote(strings.NewReader(`%A Smith, John
+%T First
+%D 2020
+
+%A John Smith
+%T Second
+%D 2020
+
+%F Smith2020b
+%T Labelled
+
+%A Smith, Jane
+%T Third
+%D 2020
+
+%T No author
+
+%T No author either
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"smith2020", "smith2020a", "Smith2020b", "smith2020c", "record5", "record6"}
+	if want, got := len(expected), len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, entry := range bib.Entries {
+		if want, got := expected[i], entry.CiteName; want != got {
+			t.Errorf("Expecting entry %d cite name %s but got %s", i, want, got)
+		}
+	}
+	for i, expected := range map[int]string{0: "a", 25: "z", 26: "aa", 27: "ab", 701: "zz", 702: "aaa"} {
+		if got := letterSuffix(i); got != expected {
+			t.Errorf("Expecting suffix %d to be %q but got %q", i, expected, got)
+		}
+	}
+}
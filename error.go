@@ -3,6 +3,7 @@ package bibtex
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -10,6 +11,42 @@ var (
 	ErrUnexpectedAtsign = errors.New("Unexpected @ sign")
 	// ErrUnknownStringVar is an error for looking up undefined string var.
 	ErrUnknownStringVar = errors.New("Unknown string variable")
+	// ErrUnexpectedEqual is an error for a field before the cite key of an entry.
+	ErrUnexpectedEqual = errors.New("Unexpected = before cite key, expecting @type{key, field = value}")
+	// ErrEmptyStringEntry is an error for a @string without a definition.
+	ErrEmptyStringEntry = errors.New("Expecting name = value in @string")
+	// ErrUnterminatedString is an error for a quoted string missing its
+	// closing quote.
+	ErrUnterminatedString = errors.New("Unterminated quoted string")
+	// ErrRedefinedStringVar is an error for a string var defined twice.
+	ErrRedefinedStringVar = errors.New("String variable redefined")
+	// ErrEmptyField is an error for a field with an empty value.
+	ErrEmptyField = errors.New("Empty field value")
+	// ErrSuspiciousAuthors is an error for an author list that seems to be
+	// separated by commas instead of "and", see BibEntry.SuspiciousAuthorList.
+	ErrSuspiciousAuthors = errors.New("Authors separated by commas instead of and")
+	// ErrDuplicateField is an error for a field defined twice in one entry.
+	ErrDuplicateField = errors.New("Duplicate field")
+	// ErrFieldNameSpace is an error for whitespace in a field name.
+	ErrFieldNameSpace = errors.New("Whitespace in field name")
+	// ErrDuplicateKey is an error for two entries with the same cite name.
+	ErrDuplicateKey = errors.New("Duplicate cite key")
+	// ErrInvalidKey is an error for a cite key that cannot be parsed back.
+	ErrInvalidKey = errors.New("Invalid cite key")
+	// ErrUnknownCiteKey is an error for a reference to an undefined entry.
+	ErrUnknownCiteKey = errors.New("Unknown cite key")
+	// ErrCrossrefOrder is an error for an entry following its crossref parent.
+	ErrCrossrefOrder = errors.New("Crossref parent must follow its children")
+	// ErrMissingField is an error for an entry missing a required field.
+	ErrMissingField = errors.New("Missing required field")
+	// ErrInvalidISBN is an error for an isbn field that is not a valid ISBN.
+	ErrInvalidISBN = errors.New("Invalid ISBN")
+	// ErrInvalidISSN is an error for an issn field that is not a valid ISSN.
+	ErrInvalidISSN = errors.New("Invalid ISSN")
+	// ErrBadQuery is an error for a malformed query expression.
+	ErrBadQuery = errors.New("Invalid query")
+	// ErrBadEndNoteTag is an error for a malformed line in an EndNote file.
+	ErrBadEndNoteTag = errors.New("Malformed EndNote tag")
 )
 
 // ErrParse is a parse error.
@@ -21,3 +58,35 @@ type ErrParse struct {
 func (e *ErrParse) Error() string {
 	return fmt.Sprintf("Parse failed at %s: %s", e.Pos, e.Err)
 }
+
+// LimitExceededError is an error for input over a limit of the parser, see
+// Parser.MaxEntries and Parser.MaxValueBytes.
+type LimitExceededError struct {
+	Pos   tokenPos
+	Limit string // Name of the limit, e.g. MaxEntries.
+	Max   int    // Value of the limit.
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("Parse failed at %s: %s of %d exceeded", e.Pos, e.Limit, e.Max)
+}
+
+// CyclicMacroError is an error for string vars defined in terms of each other.
+type CyclicMacroError struct {
+	Cycle []string // Names of the string vars, starting and ending with the same one.
+}
+
+func (e *CyclicMacroError) Error() string {
+	return fmt.Sprintf("Cyclic string variables: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// ErrParseFiles is a list of errors from parsing multiple files.
+type ErrParseFiles []error
+
+func (e ErrParseFiles) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,483 @@
+package bibtex
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keywords returns the keywords of entry, from its keywords field split at
+// commas and semicolons outside of braces. Empty keywords are dropped.
+func (entry *BibEntry) Keywords() []string {
+	val, ok := entry.field("keywords")
+	if !ok {
+		return nil
+	}
+	return splitTopLevel(val.String(), ",;")
+}
+
+// splitTopLevel splits s at any of the runes in seps that are not inside
+// braces, trims the parts and drops the empty ones.
+func splitTopLevel(s string, seps string) []string {
+	var parts []string
+	depth, start := 0, 0
+	add := func(part string) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	for i, ch := range s {
+		switch {
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+		case depth == 0 && strings.ContainsRune(seps, ch):
+			add(s[start:i])
+			start = i + 1
+		}
+	}
+	add(s[start:])
+	return parts
+}
+
+// titleFields lists the fields that may hold the title of an entry of a given
+// type, in order of preference.
+var titleFields = map[string][]string{
+	"inbook":        {"title", "chapter", "booktitle"},
+	"incollection":  {"title", "booktitle"},
+	"inproceedings": {"title", "booktitle"},
+	"conference":    {"title", "booktitle"},
+}
+
+// DisplayTitle returns the title of entry for display, with LaTeX special
+// characters decoded and protective braces removed. The title field is used
+// if set, otherwise a fallback that fits the entry type (e.g. booktitle for
+// an incollection).
+func (entry *BibEntry) DisplayTitle() string {
+	fields, ok := titleFields[foldKey(entry.Type)]
+	if !ok {
+		fields = []string{"title", "booktitle"}
+	}
+	return decodeLaTeX(entry.firstField(fields...), true)
+}
+
+// DisplayNote returns the note field of entry as plain text, see
+// LaTeXToPlain.
+func (entry *BibEntry) DisplayNote() string {
+	return LaTeXToPlain(entry.firstField("note"))
+}
+
+// DisplayAnnote returns the annote (annotation) field of entry as plain text,
+// see LaTeXToPlain.
+func (entry *BibEntry) DisplayAnnote() string {
+	return LaTeXToPlain(entry.firstField("annote", "annotation"))
+}
+
+// AuthorLastNames returns the last names of the authors of entry, e.g. for
+// the headwords of an author index. Names are decoded and stripped of braces
+// and accents, e.g. {\"O}zt{\"u}rk becomes Ozturk; a corporate author is
+// returned as a whole.
+func (entry *BibEntry) AuthorLastNames() []string {
+	list := ParseNames(entry.firstField("author"))
+	names := make([]string, len(list.Names))
+	for i, n := range list.Names {
+		names[i] = stripAccents(decodeLaTeX(n.Last, true))
+	}
+	return names
+}
+
+// leadingYear matches the year at the start of a year or date field, e.g.
+// {2020}, 2020a or 2020-05-01/2020-05-03.
+var leadingYear = regexp.MustCompile(`^[{\s]*([0-9]+)`)
+
+// Year returns the year of entry, from its year field or else the start of its
+// BibLaTeX date field. It returns false if neither holds a year.
+func (entry *BibEntry) Year() (int, bool) {
+	for _, name := range []string{"year", "date"} {
+		if m := leadingYear.FindStringSubmatch(entry.firstField(name)); m != nil {
+			if year, err := strconv.Atoi(m[1]); err == nil {
+				return year, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// plainYear matches a year field holding only a year, e.g. 2020 or {2020}.
+var plainYear = regexp.MustCompile(`^[0-9]{4}$`)
+
+// monthNumber returns the number of the month in a month field value, which
+// is a number from 1 to 12, a month name or its abbreviation, e.g. March, mar
+// or Mar. It returns false for other values.
+func monthNumber(s string) (int, bool) {
+	s = strings.ToLower(strings.TrimSpace(decodeLaTeX(s, true)))
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n >= 1 && n <= 12
+	}
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if s == name || s == name[:3] || s == name[:3]+"." {
+			return int(m), true
+		}
+	}
+	return 0, false
+}
+
+// dateValue returns the year and month fields of entry as a BibLaTeX date,
+// e.g. 2020-03 for year 2020 and month mar, or 2020 without a month, and the
+// names of the fields. It returns false if entry has a date field already,
+// has no year, or a year or month that is not a plain year or month.
+func (entry *BibEntry) dateValue() (date, yearField, monthField string, ok bool) {
+	for name := range entry.Fields {
+		switch foldKey(name) {
+		case "date":
+			return "", "", "", false
+		case "year":
+			yearField = name
+		case "month":
+			monthField = name
+		}
+	}
+	if yearField == "" {
+		return "", "", "", false
+	}
+	date = strings.TrimSpace(decodeLaTeX(entry.Fields[yearField].String(), true))
+	if !plainYear.MatchString(date) {
+		return "", "", "", false
+	}
+	if monthField != "" {
+		month, ok := monthNumber(entry.Fields[monthField].String())
+		if !ok {
+			return "", "", "", false
+		}
+		date += fmt.Sprintf("-%02d", month)
+	}
+	return date, yearField, monthField, true
+}
+
+// Pair is a field name and its value.
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// Pairs returns the fields of entry in source order, the order they were
+// added in. If decode is set, LaTeX special characters in the values are
+// decoded and protective braces removed, as for DisplayTitle. Values of
+// fields such as url, doi and file are never decoded.
+func (entry *BibEntry) Pairs(decode bool) []Pair {
+	names := entry.fieldNames()
+	pairs := make([]Pair, len(names))
+	for i, name := range names {
+		value := entry.Fields[name].String()
+		if decode {
+			value = entry.decodeField(name, value)
+		}
+		pairs[i] = Pair{Key: name, Value: value}
+	}
+	return pairs
+}
+
+// displayOrder lists the fields of each entry type in the order they are
+// presented by DisplayFields.
+var displayOrder = map[string][]string{
+	"article":       {"author", "title", "journal", "volume", "number", "pages", "year", "month", "doi", "url"},
+	"book":          {"author", "editor", "title", "edition", "series", "volume", "publisher", "address", "year", "isbn"},
+	"inbook":        {"author", "editor", "title", "chapter", "pages", "publisher", "address", "year"},
+	"incollection":  {"author", "title", "editor", "booktitle", "pages", "publisher", "address", "year"},
+	"inproceedings": {"author", "title", "editor", "booktitle", "pages", "organization", "publisher", "address", "year"},
+	"mastersthesis": {"author", "title", "school", "address", "year"},
+	"phdthesis":     {"author", "title", "school", "address", "year"},
+	"techreport":    {"author", "title", "institution", "number", "address", "year"},
+}
+
+// defaultDisplayOrder is the display order of the other entry types.
+var defaultDisplayOrder = []string{"author", "editor", "title", "howpublished", "publisher", "year", "url"}
+
+// DisplayFields returns the fields of entry for presentation, decoded as by
+// Pairs. The fields are ordered as usual for the type of entry, e.g. author,
+// title, journal, volume, number, pages and year for an article, followed
+// by any others in source order. This is independent of Formatter.FieldOrder.
+func (entry *BibEntry) DisplayFields() []Pair {
+	order, ok := displayOrder[foldKey(entry.Type)]
+	if !ok {
+		order = defaultDisplayOrder
+	}
+	pairs := entry.Pairs(true)
+	names := make([]string, len(pairs))
+	index := make(map[string]Pair, len(pairs))
+	for i, pair := range pairs {
+		names[i] = pair.Key
+		index[pair.Key] = pair
+	}
+	sortFields(names, order)
+	for i, name := range names {
+		pairs[i] = index[name]
+	}
+	return pairs
+}
+
+// sortFields sorts the field names listed in order before the others, in
+// that order. The other names keep their order. Names are compared
+// case-insensitively.
+func sortFields(names []string, order []string) {
+	priority := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := priority[foldKey(name)]; !ok {
+			priority[foldKey(name)] = i - len(order) // Before unlisted fields.
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return priority[foldKey(names[i])] < priority[foldKey(names[j])]
+	})
+}
+
+// FieldMergePolicy decides which value is kept when merging two entries that
+// both have a non-empty value for a field.
+type FieldMergePolicy int
+
+const (
+	// PreferThis keeps the value of the entry merged into.
+	PreferThis FieldMergePolicy = iota
+	// PreferOther takes the value of the other entry.
+	PreferOther
+	// PreferLonger keeps the longer value, preferring this entry on a tie.
+	PreferLonger
+)
+
+// takeOther returns true if the value theirs replaces mine under policy.
+func (policy FieldMergePolicy) takeOther(mine, theirs BibString) bool {
+	m, t := strings.TrimSpace(mine.String()), strings.TrimSpace(theirs.String())
+	switch {
+	case t == "":
+		return false
+	case m == "", policy == PreferOther:
+		return true
+	case policy == PreferLonger:
+		return len(t) > len(m)
+	}
+	return false
+}
+
+// Merge merges the fields of other, e.g. a duplicate record of the same work,
+// into entry. Fields missing or empty in entry are taken from other, fields
+// set in both are resolved by policy and the keywords of both are combined.
+// The type and cite name of entry are kept.
+func (entry *BibEntry) Merge(other *BibEntry, policy FieldMergePolicy) {
+	names := make(map[string]string, len(entry.Fields))
+	for _, name := range entry.fieldNames() {
+		if _, ok := names[foldKey(name)]; !ok {
+			names[foldKey(name)] = name
+		}
+	}
+	keywords := entry.Keywords()
+
+	for _, name := range other.fieldNames() {
+		val := other.Fields[name]
+		this, ok := names[foldKey(name)]
+		if !ok {
+			names[foldKey(name)] = name
+			entry.AddField(name, val)
+			continue
+		}
+		if policy.takeOther(entry.Fields[this], val) {
+			entry.AddField(this, val)
+		}
+	}
+
+	if len(keywords) == 0 || len(other.Keywords()) == 0 {
+		return
+	}
+	seen := make(map[string]bool, len(keywords))
+	for _, keyword := range keywords {
+		seen[foldKey(keyword)] = true
+	}
+	for _, keyword := range other.Keywords() {
+		if !seen[foldKey(keyword)] {
+			seen[foldKey(keyword)] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+	name, _ := entry.fieldName("keywords")
+	entry.AddField(name, NewBibConst(strings.Join(keywords, ", ")))
+}
+
+// FindSubsumed finds the entries of bib whose fields all appear, with the same
+// value, in another entry, e.g. a record of a work missing the fields found in
+// a more complete record of it. It returns pairs of cite names, the subsumed
+// entry first. Values are compared with LaTeX decoded, case folded and spaces
+// collapsed. Of two entries with the same fields, the later is reported as
+// subsumed by the earlier. Entries without fields are skipped.
+func (bib *BibTex) FindSubsumed() [][2]string {
+	fields := make([]map[string]string, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		fields[i] = entry.normalizedFields()
+	}
+	var pairs [][2]string
+	for i, sub := range fields {
+		if len(sub) == 0 {
+			continue
+		}
+		for j, sup := range fields {
+			if i == j || len(sub) > len(sup) || len(sub) == len(sup) && i < j {
+				continue
+			}
+			if subsumes(sup, sub) {
+				pairs = append(pairs, [2]string{bib.Entries[i].CiteName, bib.Entries[j].CiteName})
+			}
+		}
+	}
+	return pairs
+}
+
+// normalizedFields returns the non-empty fields of entry by folded name, with
+// their values normalized for comparison.
+func (entry *BibEntry) normalizedFields() map[string]string {
+	fields := make(map[string]string, len(entry.Fields))
+	for name, val := range entry.Fields {
+		name = foldKey(name)
+		value := strings.Join(strings.Fields(entry.decodeField(name, val.String())), " ")
+		if name == "pages" {
+			value = normalizePages(value)
+		}
+		if value != "" {
+			fields[name] = foldKey(value)
+		}
+	}
+	return fields
+}
+
+// subsumes returns true if every field of sub has the same value in sup.
+func subsumes(sup, sub map[string]string) bool {
+	for name, value := range sub {
+		if v, ok := sup[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// RenameField renames the field old to new in the entries of bib that have
+// it, see BibEntry.RenameField.
+func (bib *BibTex) RenameField(old, new string, policy FieldMergePolicy) {
+	for _, entry := range bib.Entries {
+		entry.RenameField(old, new, policy)
+	}
+}
+
+// RenameField renames the field old of entry, if set, to new, keeping its
+// place among the fields. Names are compared case-insensitively. If entry
+// already has a field new, it keeps its place and its value is resolved by
+// policy as in Merge, with the value of old as the other value.
+func (entry *BibEntry) RenameField(old, new string, policy FieldMergePolicy) {
+	names := entry.fieldNames()
+	var from, to string
+	for _, name := range names {
+		switch foldKey(name) {
+		case foldKey(old):
+			from = name
+		case foldKey(new):
+			to = name
+		}
+	}
+	if from == "" {
+		return
+	}
+	val := entry.Fields[from]
+	delim, parsed := entry.delims[from]
+	delete(entry.Fields, from)
+	delete(entry.delims, from)
+
+	order := names[:0]
+	for _, name := range names {
+		switch {
+		case name != from:
+			order = append(order, name)
+		case to == "":
+			order = append(order, new) // Takes the place of old.
+		}
+	}
+	entry.order = order
+	if to == "" {
+		to = new
+	} else if !policy.takeOther(entry.Fields[to], val) {
+		return
+	}
+	entry.Fields[to] = val
+	delete(entry.delims, to)
+	if parsed {
+		entry.delims[to] = delim
+	}
+}
+
+// pageRange matches a page range with a hyphen, any number of dashes or an en
+// or em dash between the pages, e.g. 1-10 or 1–10.
+var pageRange = regexp.MustCompile(`^(\s*)([^\s\-–—]*\d[^\s\-–—]*)\s*(?:-+|–|—)\s*([^\s\-–—]*\d[^\s\-–—]*)(\s*)$`)
+
+// normalizePages writes the page ranges in the comma separated list s with the
+// conventional -- between the pages. Other values, e.g. single pages or
+// article numbers like e1234, are left as they are.
+func normalizePages(s string) string {
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		parts[i] = pageRange.ReplaceAllString(part, "$1$2--$3$4")
+	}
+	return strings.Join(parts, ",")
+}
+
+// FileRef is a file attached to an entry, see Files.
+type FileRef struct {
+	Description string
+	Path        string
+	Type        string // e.g. PDF.
+}
+
+// Files returns the files listed in the file field of entry, as written by
+// JabRef and Zotero: description:path:type triples separated by semicolons,
+// with \ escaping a literal colon, semicolon or backslash. A file given by its
+// path only is also accepted.
+func (entry *BibEntry) Files() []FileRef {
+	val, ok := entry.field("file")
+	if !ok {
+		return nil
+	}
+	var files []FileRef
+	var parts []string
+	var part strings.Builder
+	end := func(endFile bool) {
+		parts = append(parts, part.String())
+		part.Reset()
+		if !endFile {
+			return
+		}
+		switch {
+		case len(parts) == 1 && parts[0] != "":
+			files = append(files, FileRef{Path: parts[0]})
+		case len(parts) == 2:
+			files = append(files, FileRef{Description: parts[0], Path: parts[1]})
+		case len(parts) >= 3:
+			files = append(files, FileRef{Description: parts[0], Path: strings.Join(parts[1:len(parts)-1], ":"), Type: parts[len(parts)-1]})
+		}
+		parts = nil
+	}
+	s := val.String()
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\\' && i+1 < len(s) && strings.IndexByte(`:;\`, s[i+1]) >= 0:
+			i++
+			part.WriteByte(s[i])
+		case ch == ':':
+			end(false)
+		case ch == ';':
+			end(true)
+		default:
+			part.WriteByte(ch)
+		}
+	}
+	end(true)
+	return files
+}
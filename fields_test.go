@@ -0,0 +1,333 @@
+package bibtex
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeywords(t *testing.T) {
+	for value, expected := range map[string][]string{
+		"go, parser, bibtex":        {"go", "parser", "bibtex"},
+		"go; parser ;bibtex;":       {"go", "parser", "bibtex"},
+		"{Smith, Jones} model, , x": {"{Smith, Jones} model", "x"},
+		"":                          nil,
+	} {
+		entry := NewBibEntry("article", "abcd")
+		entry.AddField("keywords", NewBibConst(value))
+		if want, got := expected, entry.Keywords(); !reflect.DeepEqual(want, got) {
+			t.Errorf("Expecting keywords %q for %q but got %q", want, value, got)
+		}
+	}
+	if got := NewBibEntry("article", "abcd").Keywords(); got != nil {
+		t.Errorf("Expecting no keywords but got %q", got)
+	}
+}
+
+func TestFieldNamesMixedCase(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@book{parent, Title = {Parent}, Publisher = {P}}
+@inbook{child, Crossref = {parent}, Keywords = {go, bibtex}, Year = 2020, File = {:a.pdf:PDF}, Url = {https://doi.org/10.1000/X}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, child := bib.Entries[0], bib.Entries[1]
+	if want, got := []string{"go", "bibtex"}, child.Keywords(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting keywords %q but got %q", want, got)
+	}
+	if want, got := "Parent", parent.DisplayTitle(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if year, ok := child.Year(); !ok || year != 2020 {
+		t.Errorf("Expecting year 2020 but got %d (%t)", year, ok)
+	}
+	if want, got := []FileRef{{Path: "a.pdf", Type: "PDF"}}, child.Files(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting files %v but got %v", want, got)
+	}
+	if want, got := []string{"parent"}, bib.CrossrefGraph()["child"]; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting crossref graph %v but got %v", want, got)
+	}
+	if errs := bib.CheckCrossrefOrder(); len(errs) != 1 {
+		t.Errorf("Expecting the crossref to be out of order but got %v", errs)
+	}
+	if err := bib.ResolveCrossrefs(nil); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "Parent", child.DisplayTitle(); want != got {
+		t.Errorf("Expecting inherited book title %q but got %q", want, got)
+	}
+	bib.PromoteDOIs(true)
+	if _, ok := child.Fields["Url"]; ok {
+		t.Errorf("Expecting the DOI to be moved out of the Url field: %v", child.Fields)
+	}
+	if want, got := NewBibConst("10.1000/x"), child.Fields["doi"]; want != got {
+		t.Errorf("Expecting doi %q but got %q", want, got)
+	}
+}
+
+func TestEntryMerge(t *testing.T) {
+	newEntries := func() (*BibEntry, *BibEntry) {
+		a := NewBibEntry("article", "smith2020")
+		a.AddField("title", NewBibConst("A Title"))
+		a.AddField("journal", NewBibConst(""))
+		a.AddField("keywords", NewBibConst("go, parser"))
+		b := NewBibEntry("misc", "Smith:2020")
+		b.AddField("Title", NewBibConst("A Longer Title"))
+		b.AddField("journal", NewBibConst("Journal"))
+		b.AddField("year", NewBibConst("2020"))
+		b.AddField("keywords", NewBibConst("Parser; bibtex"))
+		return a, b
+	}
+	for policy, title := range map[FieldMergePolicy]string{
+		PreferThis:   "A Title",
+		PreferOther:  "A Longer Title",
+		PreferLonger: "A Longer Title",
+	} {
+		a, b := newEntries()
+		a.Merge(b, policy)
+		expected := map[string]string{
+			"title":    title,
+			"journal":  "Journal",
+			"year":     "2020",
+			"keywords": "go, parser, bibtex",
+		}
+		if want, got := len(expected), len(a.Fields); want != got {
+			t.Errorf("Policy %d: expecting %d fields but got %d: %v", policy, want, got, a.Fields)
+		}
+		for field, value := range expected {
+			if got, ok := a.Fields[field]; !ok || got.String() != value {
+				t.Errorf("Policy %d: expecting %s %q but got %v", policy, field, value, got)
+			}
+		}
+		if a.Type != "article" || a.CiteName != "smith2020" {
+			t.Errorf("Policy %d: expecting type and cite name to be kept but got %s %s", policy, a.Type, a.CiteName)
+		}
+	}
+
+	a, b := newEntries()
+	b.AddField("Title", NewBibConst("Short"))
+	a.Merge(b, PreferLonger)
+	if want, got := "A Title", a.Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+}
+
+// Tests that fields taken from the other entry are added in its field order.
+func TestEntryMergeOrder(t *testing.T) {
+	a := NewBibEntry("article", "a")
+	a.AddField("Keywords", NewBibConst("go"))
+	b := NewBibEntry("article", "a")
+	for _, name := range []string{"year", "title", "pages", "doi", "note", "author", "keywords"} {
+		b.AddField(name, NewBibConst(name))
+	}
+	a.Merge(b, PreferThis)
+	expected := []Pair{
+		{"Keywords", "go, keywords"},
+		{"year", "year"},
+		{"title", "title"},
+		{"pages", "pages"},
+		{"doi", "doi"},
+		{"note", "note"},
+		{"author", "author"},
+	}
+	if got := a.Pairs(false); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting fields %v but got %v", expected, got)
+	}
+}
+
+func TestFindSubsumed(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{short, title = {The {Go} Language}, year = 2020}
+@article{full,
+  Title = {The Go  language},
+  author = {Doe, Jane},
+  year = {2020},
+  pages = {1-10},
+}
+@article{pages, title = {The Go Language}, pages = {1--10}}
+@article{copy, title = {The Go Language}, year = 2020}
+@article{other, title = {The Go Language}, year = 2021}
+@misc{empty,}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]string{
+		{"short", "full"},
+		{"pages", "full"},
+		{"copy", "short"},
+		{"copy", "full"},
+	}
+	if got := bib.FindSubsumed(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting %v but got %v", expected, got)
+	}
+}
+
+func TestRenameField(t *testing.T) {
+	input := `@article{a, title = {A}, Journal = "J. Go", year = 2020}
+@article{b, journal = {Short}, title = {B}, journaltitle = {Journal of Go}}
+@book{c, title = {C}}
+`
+	for policy, expected := range map[FieldMergePolicy][][]Pair{
+		PreferThis: {
+			{{"title", "A"}, {"journaltitle", "J. Go"}, {"year", "2020"}},
+			{{"title", "B"}, {"journaltitle", "Journal of Go"}},
+			{{"title", "C"}},
+		},
+		PreferOther: {
+			{{"title", "A"}, {"journaltitle", "J. Go"}, {"year", "2020"}},
+			{{"title", "B"}, {"journaltitle", "Short"}},
+			{{"title", "C"}},
+		},
+	} {
+		bib, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bib.RenameField("journal", "journaltitle", policy)
+		for i, entry := range bib.Entries {
+			if got := entry.Pairs(false); !reflect.DeepEqual(expected[i], got) {
+				t.Errorf("Policy %d: expecting %s fields %v but got %v", policy, entry.CiteName, expected[i], got)
+			}
+		}
+		if want, got := DelimQuotes, bib.Entries[0].Delimiter("journaltitle"); want != got {
+			t.Errorf("Expecting delimiter %v to be kept but got %v", want, got)
+		}
+	}
+}
+
+func TestPairs(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a,
+  year = 2020,
+  title = {The {\"O}ffice},
+  author = "Doe, John",
+  Abstract = {x},
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	entry.AddField("title", NewBibConst("Changed"))
+	entry.AddField("note", NewBibConst("Added"))
+	delete(entry.Fields, "author")
+	entry.Fields["doi"] = NewBibConst("10.1/x")
+	expected := []Pair{
+		{"year", "2020"},
+		{"title", "Changed"},
+		{"Abstract", "x"},
+		{"note", "Added"},
+		{"doi", "10.1/x"},
+	}
+	if got := entry.Pairs(false); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting pairs %v but got %v", expected, got)
+	}
+
+	expected = []Pair{{"year", "2020"}, {"title", `The Öffice`}, {"author", "Doe, John"}, {"Abstract", "x"}}
+	bib, err = Parse(strings.NewReader(`@article{a, year = 2020, title = {The {\"O}ffice}, author = "Doe, John", Abstract = {x}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bib.Entries[0].Pairs(true); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting decoded pairs %v but got %v", expected, got)
+	}
+}
+
+// Tests that url, doi and file values are not decoded as LaTeX.
+func TestPairsVerbatim(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, title = {a--b}, URL = {http://x/a--b}, doi = {10.1000/a--{b}}, file = {C:\u\x.pdf}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Pair{{"title", "a–b"}, {"URL", "http://x/a--b"}, {"doi", "10.1000/a--{b}"}, {"file", `C:\u\x.pdf`}}
+	if got := bib.Entries[0].Pairs(true); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting decoded pairs %v but got %v", expected, got)
+	}
+	if got := bib.Entries[0].DisplayFields(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting display fields %v but got %v", expected, got)
+	}
+}
+
+func TestAuthorLastNames(t *testing.T) {
+	entry := NewBibEntry("report", "a")
+	entry.AddField("author", NewBibConst(`{\"O}zt{\"u}rk, Ay{\c{s}}e and {World Health Organization} and Ludwig van Beethoven and de la Fontaine, Jean and Jos\'{e} Mar{\'i}a {Garc{\'i}a M{\'a}rquez} and others`))
+	expected := []string{"Ozturk", "World Health Organization", "Beethoven", "Fontaine", "Garcia Marquez"}
+	if got := entry.AuthorLastNames(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting last names %q but got %q", expected, got)
+	}
+	if got := NewBibEntry("misc", "b").AuthorLastNames(); len(got) != 0 {
+		t.Errorf("Expecting no last names but got %q", got)
+	}
+}
+
+func TestDisplayFields(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a,
+  year = 2020,
+  abstract = {About},
+  Pages = {1--10},
+  journal = {J},
+  Title = {The {\"O}ffice},
+  author = {Doe, John},
+  keywords = {k},
+}
+@book{b, year = 2019, publisher = {P}, title = {B}, note = {N}, editor = {Roe, Jane}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range [][]Pair{
+		{{"author", "Doe, John"}, {"Title", "The Öffice"}, {"journal", "J"}, {"Pages", "1–10"}, {"year", "2020"}, {"abstract", "About"}, {"keywords", "k"}},
+		{{"editor", "Roe, Jane"}, {"title", "B"}, {"publisher", "P"}, {"year", "2019"}, {"note", "N"}},
+	} {
+		if got := bib.Entries[i].DisplayFields(); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expecting %s fields %v but got %v", bib.Entries[i].Type, expected, got)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	for value, expected := range map[string][]FileRef{
+		":papers/smith2020.pdf:PDF": {{Path: "papers/smith2020.pdf", Type: "PDF"}},
+		`Full text:C\:\\Users\\me\\a\;b.pdf:PDF;Slides:slides/talk.pptx:PowerPoint;`: {
+			{Description: "Full text", Path: `C:\Users\me\a;b.pdf`, Type: "PDF"},
+			{Description: "Slides", Path: "slides/talk.pptx", Type: "PowerPoint"},
+		},
+		"papers/plain.pdf": {{Path: "papers/plain.pdf"}},
+	} {
+		entry := NewBibEntry("article", "a")
+		entry.AddField("file", NewBibConst(value))
+		if got := entry.Files(); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expecting files %v for %q but got %v", expected, value, got)
+		}
+	}
+	if got := NewBibEntry("article", "a").Files(); got != nil {
+		t.Errorf("Expecting no files but got %v", got)
+	}
+}
+
+// Tests that entry types are compared by their Unicode case folding, also
+// when set directly.
+func TestEntryTypeCase(t *testing.T) {
+	entry := NewBibEntry("InProceedings", "a")
+	if want, got := "inproceedings", entry.Type; want != got {
+		t.Errorf("Expecting type %q but got %q", want, got)
+	}
+	entry.Type = "INPROCEEDINGſ"
+	entry.AddField("booktitle", NewBibConst("Proceedings"))
+	if want, got := "Proceedings", entry.DisplayTitle(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	entry.AddField("pages", NewBibConst("1"))
+	entry.AddField("title", NewBibConst("T"))
+	entry.AddField("author", NewBibConst("Doe, Jane"))
+	expected := []string{"author", "title", "booktitle", "pages"}
+	var got []string
+	for _, pair := range entry.DisplayFields() {
+		got = append(got, pair.Key)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting display order %q but got %q", expected, got)
+	}
+	if err := entry.Validate(); !errors.Is(err, ErrMissingField) || !strings.Contains(err.Error(), "year") {
+		t.Errorf("Expecting missing year error but got %v", err)
+	}
+}
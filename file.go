@@ -0,0 +1,240 @@
+package bibtex
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy decides what happens when merging BibTeX files with entries
+// of the same cite name.
+type ConflictPolicy int
+
+const (
+	// ConflictKeepFirst keeps the entry seen first, like BibTeX.
+	ConflictKeepFirst ConflictPolicy = iota
+	// ConflictKeepLast replaces the entry seen first by the later one.
+	ConflictKeepLast
+	// ConflictError fails the merge.
+	ConflictError
+)
+
+// Merge adds the entries, string vars and preambles of other to bib. Entries
+// with the same (case-insensitive) cite name as one already in bib are
+// resolved by policy; string vars defined in both are kept from bib only with
+// ConflictKeepFirst. With ConflictError, bib is left unchanged on conflict,
+// including duplicates within other.
+// @modify entries are added as they are, see ApplyModifies.
+func (bib *BibTex) Merge(other *BibTex, policy ConflictPolicy) error {
+	index := make(map[string]int, len(bib.Entries))
+	for i, entry := range bib.Entries {
+		if _, ok := index[foldKey(entry.CiteName)]; !ok && !isModify(entry) {
+			index[foldKey(entry.CiteName)] = i
+		}
+	}
+	if policy == ConflictError {
+		seen := make(map[string]bool, len(other.Entries))
+		for _, entry := range other.Entries {
+			if isModify(entry) {
+				continue
+			}
+			key := foldKey(entry.CiteName)
+			if _, exists := index[key]; exists || seen[key] {
+				return fmt.Errorf("%w: %s", ErrDuplicateKey, entry.CiteName)
+			}
+			seen[key] = true
+		}
+	}
+	for _, entry := range other.Entries {
+		if isModify(entry) {
+			bib.AddEntry(entry)
+			continue
+		}
+		i, exists := index[foldKey(entry.CiteName)]
+		switch {
+		case !exists:
+			index[foldKey(entry.CiteName)] = len(bib.Entries)
+			bib.AddEntry(entry)
+		case policy == ConflictKeepLast:
+			bib.Entries[i] = entry
+		}
+	}
+	for _, key := range other.stringVarKeys() {
+		if _, exists := bib.StringVar[key]; !exists || policy != ConflictKeepFirst {
+			bib.setStringVar(key, other.StringVar[key])
+		}
+	}
+	bib.Preambles = append(bib.Preambles, other.Preambles...)
+	return nil
+}
+
+// ParseDir parses all .bib files in dir (and its subdirectories if recursive
+// is set) into a single BibTex, using the default Parser.
+func ParseDir(dir string, recursive bool) (*BibTex, error) {
+	return new(Parser).ParseDir(dir, recursive)
+}
+
+// ParseDir parses all .bib files in dir (and its subdirectories if recursive
+// is set) in lexical order, and merges them into a single BibTex according to
+// p.Conflict. Errors and warnings are prefixed with the file name.
+//
+// If p.Recover is set, files that fail to parse or merge are skipped and the
+// merged BibTex is returned together with an ErrParseFiles listing the
+// failures.
+func (p *Parser) ParseDir(dir string, recursive bool) (*BibTex, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".bib") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := p.newCollector()
+	for _, file := range files {
+		if err := c.add(file, p.mergeFile(c.merged, file)); err != nil {
+			return nil, err
+		}
+	}
+	return c.result()
+}
+
+// ParseTar parses all .bib files in the tar archive read from r into a single
+// BibTex, using the default Parser.
+func ParseTar(r io.Reader) (*BibTex, error) {
+	return new(Parser).ParseTar(r)
+}
+
+// ParseTar parses all .bib files in the tar archive read from r, in archive
+// order, and merges them into a single BibTex like ParseDir. The member names
+// are recorded as the Source of the parsed entries. Other members are skipped.
+func (p *Parser) ParseTar(r io.Reader) (*BibTex, error) {
+	tr := tar.NewReader(r)
+	c := p.newCollector()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg || !strings.EqualFold(path.Ext(hdr.Name), ".bib") {
+			continue
+		}
+		if err := c.add(hdr.Name, p.merge(c.merged, tr, hdr.Name)); err != nil {
+			return nil, err
+		}
+	}
+	return c.result()
+}
+
+// collector merges parsed files into one BibTex, see ParseDir.
+type collector struct {
+	p        *Parser
+	merged   *BibTex
+	errs     ErrParseFiles
+	warnings []error
+	diags    []Diagnostic
+}
+
+func (p *Parser) newCollector() *collector {
+	return &collector{p: p, merged: NewBibTex()}
+}
+
+// add records the warnings, diagnostics and error of merging the named file. The error is
+// only returned if the parser does not recover from it.
+func (c *collector) add(name string, err error) error {
+	for _, w := range c.p.Warnings {
+		c.warnings = append(c.warnings, fmt.Errorf("%s: %w", name, w))
+	}
+	c.diags = append(c.diags, c.p.Diagnostics...)
+	// Not to be recorded again if the next file fails to open.
+	c.p.Warnings, c.p.Diagnostics = nil, nil
+	if err != nil {
+		if !c.p.Recover {
+			c.p.Warnings, c.p.Diagnostics = c.warnings, c.diags
+			return err
+		}
+		c.errs = append(c.errs, err)
+	}
+	return nil
+}
+
+// result returns the merged BibTex, with the errors recovered from if any.
+func (c *collector) result() (*BibTex, error) {
+	c.p.Warnings, c.p.Diagnostics = c.warnings, c.diags
+	if len(c.errs) > 0 {
+		return c.merged, c.errs
+	}
+	return c.merged, nil
+}
+
+// ParseFile parses the named BibTeX file using the default Parser.
+func ParseFile(file string) (*BibTex, error) {
+	return new(Parser).ParseFile(file)
+}
+
+// ParseFile parses the named BibTeX file, recording it as the Source of the
+// parsed entries. Parse errors are prefixed with the file name.
+func (p *Parser) ParseFile(file string) (*BibTex, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return p.parseNamed(f, file)
+}
+
+// parseNamed parses r, read from the named file, recording the name as the
+// Source of the parsed entries.
+func (p *Parser) parseNamed(r io.Reader, name string) (*BibTex, error) {
+	bib, err := p.Parse(r)
+	for i := range p.Diagnostics {
+		p.Diagnostics[i].File = name
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	for _, entry := range bib.Entries {
+		entry.Source = name
+	}
+	return bib, nil
+}
+
+// mergeFile parses file and merges it into bib.
+func (p *Parser) mergeFile(bib *BibTex, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.merge(bib, f, file)
+}
+
+// merge parses r, read from the named file, and merges it into bib.
+func (p *Parser) merge(bib *BibTex, r io.Reader, name string) error {
+	parsed, err := p.parseNamed(r, name)
+	if err != nil {
+		return err
+	}
+	if err := bib.Merge(parsed, p.Conflict); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
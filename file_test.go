@@ -0,0 +1,237 @@
+package bibtex
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates a temporary directory with the given files.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bibtex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.bib":       "@string{pub = {Publisher}}\n@book{shared, title = {From a}, publisher = pub}\n",
+		"b.bib":       "@article{b, title = {B}}\n@book{shared, title = {From b}}\n",
+		"notes.txt":   "@article{ignored, title = {Not a bib file}}\n",
+		"sub/c.bib":   "@article{c, title = {C}}\n",
+		"sub/bad.bib": "@article{bad, title = {Unterminated}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	bib, err := ParseDir(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	if want, got := "From a", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting first definition %q to be kept but got %q", want, got)
+	}
+
+	p := &Parser{Conflict: ConflictKeepLast}
+	if _, err := p.ParseDir(dir, true); err == nil {
+		t.Error("Expecting error for sub/bad.bib")
+	}
+
+	p.Recover = true
+	bib, err = p.ParseDir(dir, true)
+	var errs ErrParseFiles
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("Expecting one file error but got %v", err)
+	}
+	if !strings.Contains(errs[0].Error(), "bad.bib") {
+		t.Errorf("Expecting file name in error but got %v", errs[0])
+	}
+	if want, got := 3, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	if want, got := "From b", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting last definition %q to be kept but got %q", want, got)
+	}
+
+	p = &Parser{Conflict: ConflictError}
+	if _, err := p.ParseDir(dir, false); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expecting duplicate key error but got %v", err)
+	}
+}
+
+// Tests that ConflictError rejects duplicates within a single file.
+func TestMergeDuplicateInFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.bib": "@article{x, title = {One}}\n@article{X, title = {Two}}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	p := &Parser{Conflict: ConflictError}
+	if _, err := p.ParseDir(dir, false); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expecting duplicate key error but got %v", err)
+	}
+
+	bib := NewBibTex()
+	other, err := Parse(strings.NewReader("@article{y, title = {Y}}\n@misc{Y, note = {N}}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.Merge(other, ConflictError); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expecting duplicate key error but got %v", err)
+	}
+	if want, got := 0, len(bib.Entries); want != got {
+		t.Errorf("Expecting %d entries to be left alone but got %d", want, got)
+	}
+}
+
+// Tests that @modify entries are not conflicts and still apply after merging.
+func TestMergeModify(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.bib": "@article{a, title = {Old}}\n@modify{a, title = {New}}\n",
+		"b.bib": "@modify{A, note = {Note}}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	for _, policy := range []ConflictPolicy{ConflictKeepFirst, ConflictKeepLast, ConflictError} {
+		p := &Parser{Conflict: policy}
+		bib, err := p.ParseDir(dir, false)
+		if err != nil {
+			t.Fatalf("Policy %d: %v", policy, err)
+		}
+		if want, got := 3, len(bib.Entries); want != got {
+			t.Fatalf("Policy %d: expecting %d entries but got %d", policy, want, got)
+		}
+		if err := bib.ApplyModifies(); err != nil {
+			t.Fatalf("Policy %d: %v", policy, err)
+		}
+		if want, got := 1, len(bib.Entries); want != got {
+			t.Fatalf("Policy %d: expecting %d entry but got %d", policy, want, got)
+		}
+		entry := bib.Entries[0]
+		if entry.Type != "article" || entry.Fields["title"].String() != "New" || entry.Fields["note"].String() != "Note" {
+			t.Errorf("Policy %d: expecting modified article but got %s", policy, entry)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.bib": "@article{a1, title = {A1}}\n@article{a2, title = {A2}}\n",
+		"b.bib": "@article{b1, title = {B1}}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.bib"), filepath.Join(dir, "b.bib")
+	merged, err := ParseFile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := merged.Merge(other, ConflictError); err != nil {
+		t.Fatal(err)
+	}
+	for i, source := range []string{a, a, b} {
+		if want, got := source, merged.Entries[i].Source; want != got {
+			t.Errorf("Expecting %s source %s but got %s", merged.Entries[i].CiteName, want, got)
+		}
+	}
+
+	fromDir, err := ParseDir(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := b, fromDir.Entries[2].Source; want != got {
+		t.Errorf("Expecting source %s but got %s", want, got)
+	}
+
+	parsed, err := Parse(strings.NewReader("@article{x, title = {X}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Entries[0].Source; got != "" {
+		t.Errorf("Expecting no source but got %s", got)
+	}
+}
+
+// writeTar returns a tar archive of the given files, in order.
+func writeTar(t *testing.T, files ...[2]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range files {
+		hdr := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestParseTar(t *testing.T) {
+	files := [][2]string{
+		{"data/a.bib", "@string{pub = {Publisher}}\n@book{shared, title = {From a}, publisher = pub}\n"},
+		{"README", "@article{ignored, title = {Not a bib file}}\n"},
+		{"data/b.BIB", "@article{b, title = {B}, year = x}\n@book{shared, title = {From b}}\n"},
+	}
+	bib, err := ParseTar(writeTar(t, files...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, expected := range [][2]string{{"From a", "data/a.bib"}, {"B", "data/b.BIB"}} {
+		entry := bib.Entries[i]
+		if want, got := expected[0], entry.Fields["title"].String(); want != got {
+			t.Errorf("Expecting title %q but got %q", want, got)
+		}
+		if want, got := expected[1], entry.Source; want != got {
+			t.Errorf("Expecting %s source %s but got %s", entry.CiteName, want, got)
+		}
+	}
+
+	p := &Parser{Conflict: ConflictKeepLast}
+	bib, err = p.ParseTar(writeTar(t, files...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "From b", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting last definition %q to be kept but got %q", want, got)
+	}
+	if len(p.Warnings) != 1 || !strings.HasPrefix(p.Warnings[0].Error(), "data/b.BIB: ") {
+		t.Errorf("Expecting one warning for data/b.BIB but got %v", p.Warnings)
+	}
+
+	p = &Parser{Conflict: ConflictError}
+	if _, err := p.ParseTar(writeTar(t, files...)); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expecting duplicate key error but got %v", err)
+	}
+}
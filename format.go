@@ -0,0 +1,326 @@
+package bibtex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Formatter pretty prints BibTeX entries with a configurable layout.
+type Formatter struct {
+	// TrailingComma writes a comma after the last field of an entry.
+	TrailingComma bool
+
+	// EscapePercent writes a raw % in values as \%, which would otherwise
+	// start a comment when the value is processed by LaTeX.
+	EscapePercent bool
+
+	// BlankLineBetweenEntries separates entries with an empty line.
+	BlankLineBetweenEntries bool
+
+	// Indent is written before each field.
+	Indent string
+
+	// TitleCase changes the case of the title field, leaving text in braces
+	// as it is.
+	TitleCase TitleCaseStyle
+
+	// FieldOrder lists fields in the order they are written. Other fields
+	// follow in source order.
+	FieldOrder []string
+
+	// KeyCase changes the case of cite names, and of the references to them
+	// in the crossref, xref, xdata, related and ids fields.
+	KeyCase KeyCasePolicy
+
+	// UseDateField writes the year and month fields of an entry as a single
+	// BibLaTeX date field, e.g. date = "2020-03" for year = 2020 and
+	// month = mar, or date = "2020" without a month. Entries with a date
+	// field, or a year or month that is not a plain year or month, are
+	// written as they are.
+	UseDateField bool
+}
+
+// DefaultFieldOrder is the conventional order of fields used by NewFormatter.
+var DefaultFieldOrder = []string{
+	"author", "editor", "title", "journal", "booktitle", "volume", "number",
+	"pages", "year", "month", "date", "publisher", "address", "doi", "url", "note",
+}
+
+// TitleCaseStyle is a case change applied to titles, see Formatter.
+type TitleCaseStyle int
+
+const (
+	// TitleCaseNone leaves titles as they are.
+	TitleCaseNone TitleCaseStyle = iota
+	// TitleCaseSentence capitalizes the first letter (and the first after a
+	// colon) and lowercases the rest, e.g. "A study of {BibTeX} files".
+	TitleCaseSentence
+	// TitleCaseTitle capitalizes the first letter of each word other than
+	// short function words, which are lowercased, e.g.
+	// "A Study of {BibTeX} Files".
+	TitleCaseTitle
+)
+
+// KeyCasePolicy is a case change applied to cite names, see Formatter.
+type KeyCasePolicy int
+
+const (
+	// KeyCasePreserve leaves cite names as they are.
+	KeyCasePreserve KeyCasePolicy = iota
+	// KeyCaseLower lowercases cite names.
+	KeyCaseLower
+)
+
+// NewFormatter returns a Formatter with the default layout used by
+// PrettyString: fields in DefaultFieldOrder, indented by four spaces, and
+// entries separated by a blank line. The zero Formatter writes compact output
+// with fields in source order instead.
+func NewFormatter() *Formatter {
+	return &Formatter{BlankLineBetweenEntries: true, Indent: "    ", FieldOrder: DefaultFieldOrder}
+}
+
+// Format pretty prints bib.
+func (f *Formatter) Format(bib *BibTex) string {
+	var buf bytes.Buffer
+	for i, entry := range bib.Entries {
+		if i != 0 && f.BlankLineBetweenEntries {
+			fmt.Fprint(&buf, "\n")
+		}
+		f.writeEntry(&buf, entry)
+	}
+	return buf.String()
+}
+
+// writeEntry pretty prints a single entry to w.
+func (f *Formatter) writeEntry(w io.Writer, entry *BibEntry) {
+	citeName := entry.CiteName
+	if f.KeyCase == KeyCaseLower {
+		citeName = strings.ToLower(citeName)
+	}
+	fmt.Fprintf(w, "@%s{%s,\n", entry.Type, citeName)
+
+	// Determine key order.
+	keys := entry.fieldNames()
+	var date, yearField, monthField string
+	var useDate bool
+	if f.UseDateField {
+		date, yearField, monthField, useDate = entry.dateValue()
+	}
+	if useDate {
+		dated := keys[:0]
+		for _, key := range keys {
+			switch key {
+			case yearField:
+				dated = append(dated, "date")
+			case monthField:
+			default:
+				dated = append(dated, key)
+			}
+		}
+		keys = dated
+	}
+	sortFields(keys, f.FieldOrder)
+
+	// Write fields. The indent is escaped, since it may contain tabs.
+	esc := string([]byte{tabwriter.Escape})
+	indent := esc + f.Indent + esc
+	tw := tabwriter.NewWriter(w, 1, 4, 1, ' ', tabwriter.StripEscape)
+	for i, key := range keys {
+		var value string
+		if useDate && key == "date" {
+			value = date
+		} else {
+			value = entry.Fields[key].String()
+		}
+		if f.TitleCase != TitleCaseNone && foldKey(key) == "title" {
+			value = changeCase(value, f.TitleCase)
+		}
+		if f.KeyCase == KeyCaseLower && isCrossrefField(key) {
+			value = strings.ToLower(value)
+		}
+		if f.EscapePercent {
+			value = escapePercent(value)
+		}
+		value = escapeUnbalanced(value)
+		format := stringformat(value)
+		sep := ","
+		if i == len(keys)-1 && !f.TrailingComma {
+			sep = ""
+		}
+		fmt.Fprintf(tw, "%s%s\t=\t"+format+"%s\n", indent, key, value, sep)
+	}
+	tw.Flush()
+
+	// Close.
+	fmt.Fprint(w, "}\n")
+}
+
+// minorWords are not capitalized by TitleCaseTitle, unless first.
+var minorWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true,
+	"by": true, "for": true, "in": true, "nor": true, "of": true, "on": true,
+	"or": true, "the": true, "to": true, "via": true, "with": true,
+}
+
+// changeCase changes the case of the letters in v outside of braces, and
+// outside of command names, following style.
+func changeCase(v string, style TitleCaseStyle) string {
+	var buf strings.Builder
+	depth := 0
+	first := true     // No word seen yet in the title or subtitle.
+	wordStart := true // At the start of a word.
+	for i := 0; i < len(v); {
+		r, size := utf8.DecodeRuneInString(v[i:])
+		switch {
+		case r == '{':
+			depth++
+			first = false
+		case r == '}':
+			depth--
+		case depth > 0:
+		case r == '\\': // Keep the command name.
+			name := commandName(v[i+1:])
+			buf.WriteString(v[i : i+1+len(name)])
+			i += 1 + len(name)
+			first, wordStart = false, false
+			continue
+		case unicode.IsLetter(r):
+			if wordStart {
+				end := strings.IndexFunc(v[i:], func(r rune) bool { return !unicode.IsLetter(r) })
+				if end < 0 {
+					end = len(v) - i
+				}
+				word := v[i : i+end]
+				switch {
+				case first || style == TitleCaseTitle && !minorWords[strings.ToLower(word)]:
+					r = unicode.ToUpper(r)
+				default:
+					r = unicode.ToLower(r)
+				}
+			} else if style == TitleCaseSentence {
+				r = unicode.ToLower(r)
+			}
+			first, wordStart = false, false
+		case r == ':': // The subtitle starts like the title.
+			first = true
+		default:
+			wordStart = unicode.IsSpace(r) || r == '-' || r == '"' || r == '(' || r == '/'
+		}
+		if r == '{' || r == '}' {
+			wordStart = false
+		}
+		buf.WriteRune(r)
+		i += size
+	}
+	return buf.String()
+}
+
+// escapePercent escapes each % in v that is not already escaped.
+func escapePercent(v string) string {
+	if !strings.Contains(v, "%") {
+		return v
+	}
+	var buf strings.Builder
+	backslashes := 0
+	for i := 0; i < len(v); i++ {
+		if v[i] == '%' && backslashes%2 == 0 {
+			buf.WriteByte('\\')
+		}
+		if v[i] == '\\' {
+			backslashes++
+		} else {
+			backslashes = 0
+		}
+		buf.WriteByte(v[i])
+	}
+	return buf.String()
+}
+
+// literalBraces are the replacements for unmatched braces, see
+// escapeUnbalanced.
+var literalBraces = map[byte]string{'{': `\textbraceleft{}`, '}': `\textbraceright{}`}
+
+// escapeUnbalanced makes the braces in v balanced, so that it can be written
+// in braces. Like BibTeX, the parser counts every brace, escaped or not, so
+// an unbalanced value has its escaped braces, e.g. \{, and the unescaped
+// braces without a match replaced by \textbraceleft{} or \textbraceright{},
+// which decode to literal braces. Balanced values are kept as they are.
+func escapeUnbalanced(v string) string {
+	if balanced(v) {
+		return v
+	}
+	var open []int              // Positions of unmatched open braces.
+	unmatched := map[int]bool{} // Positions of unmatched braces.
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++ // Skip escaped character.
+		case '{':
+			open = append(open, i)
+		case '}':
+			if len(open) == 0 {
+				unmatched[i] = true
+			} else {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	for _, i := range open {
+		unmatched[i] = true
+	}
+	var buf strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v) && literalBraces[v[i+1]] != "":
+			buf.WriteString(literalBraces[v[i+1]])
+			i++
+		case v[i] == '\\' && i+1 < len(v):
+			buf.WriteString(v[i : i+2])
+			i++
+		case unmatched[i]:
+			buf.WriteString(literalBraces[v[i]])
+		default:
+			buf.WriteByte(v[i])
+		}
+	}
+	return buf.String()
+}
+
+// balanced returns true if every brace in v, escaped or not, has a match.
+func balanced(v string) bool {
+	depth := 0
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// stringformat determines the correct formatting verb for the given BibTeX field value.
+func stringformat(v string) string {
+	// Numbers may be represented unquoted.
+	if _, err := strconv.Atoi(v); err == nil {
+		return "%s"
+	}
+
+	// Strings with certain characters must be brace quoted.
+	if strings.ContainsAny(v, "\"{}") {
+		return "{%s}"
+	}
+
+	// Default to quoted string. The value is written verbatim since BibTeX does
+	// not interpret backslash escapes (e.g. in file paths or LaTeX commands).
+	return `"%s"`
+}
@@ -0,0 +1,321 @@
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+const formatInput = `@article{abcd,
+  year = 2020,
+  title = {Hello World},
+  author = "Doe, John",
+}
+@misc{efgh, note = {A {B} C}}
+`
+
+func assertFormat(t *testing.T, f *Formatter, expected string) {
+	t.Helper()
+	bib, err := Parse(strings.NewReader(formatInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := f.Format(bib)
+	if s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+	bib2, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("Cannot parse output: %v", err)
+	}
+	AssertEntryListsEqual(t, bib.Entries, bib2.Entries)
+}
+
+func TestFormatTrailingComma(t *testing.T) {
+	assertFormat(t, NewFormatter(), `@article{abcd,
+    author = "Doe, John",
+    title  = "Hello World",
+    year   = 2020
+}
+
+@misc{efgh,
+    note = {A {B} C}
+}
+`)
+	f := NewFormatter()
+	f.TrailingComma = true
+	assertFormat(t, f, `@article{abcd,
+    author = "Doe, John",
+    title  = "Hello World",
+    year   = 2020,
+}
+
+@misc{efgh,
+    note = {A {B} C},
+}
+`)
+}
+
+func TestFormatEscapePercent(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a, note = {50% off, was 100\% before}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `@misc{a,
+    note = "50\% off, was 100\% before"
+}
+`
+	if s := (&Formatter{EscapePercent: true, Indent: "    "}).Format(bib); s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+	if s := NewFormatter().Format(bib); !strings.Contains(s, "50% off") {
+		t.Errorf("Expecting %% to be kept by default, got:\n%s", s)
+	}
+}
+
+func TestFormatLayout(t *testing.T) {
+	assertFormat(t, &Formatter{}, `@article{abcd,
+year   = 2020,
+title  = "Hello World",
+author = "Doe, John"
+}
+@misc{efgh,
+note = {A {B} C}
+}
+`)
+	assertFormat(t, &Formatter{BlankLineBetweenEntries: true, Indent: "\t"}, `@article{abcd,
+	year   = 2020,
+	title  = "Hello World",
+	author = "Doe, John"
+}
+
+@misc{efgh,
+	note = {A {B} C}
+}
+`)
+}
+
+func TestFormatUnbalancedBraces(t *testing.T) {
+	for value, expected := range map[string][2]string{ // Output and decoded.
+		"a { b":     {`a \textbraceleft{} b`, "a { b"},
+		"a } b {":   {`a \textbraceright{} b \textbraceleft{}`, "a } b {"},
+		"{a} } b":   {`{a} \textbraceright{} b`, "{a} } b"},
+		`a \{ b`:    {`a \textbraceleft{} b`, "a { b"},
+		`a \} b`:    {`a \textbraceright{} b`, "a } b"},
+		`a\\{ b`:    {`a\\\textbraceleft{} b`, `a\\{ b`},
+		`{a \{ b}`:  {`{a \textbraceleft{} b}`, "{a { b}"},
+		`\{a\} {`:   {`\textbraceleft{}a\textbraceright{} \textbraceleft{}`, "{a} {"},
+		`{a \{b\}}`: {`{a \{b\}}`, "{a {b}}"},
+	} {
+		bib := NewBibTex()
+		entry := NewBibEntry("misc", "a")
+		entry.AddField("note", NewBibConst(value))
+		bib.AddEntry(entry)
+
+		s := NewFormatter().Format(bib)
+		if want := "    note = {" + expected[0] + "}\n"; !strings.Contains(s, want) {
+			t.Errorf("%q: expecting %q in output, got:\n%s", value, want, s)
+		}
+		bib2, err := Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("%q: cannot parse output: %v\n%s", value, err, s)
+		}
+		note := bib2.Entries[0].Fields["note"].String()
+		if want, got := expected[0], note; want != got {
+			t.Errorf("%q: expecting value %q after round trip but got %q", value, want, got)
+		}
+		if want, got := expected[1], DecodeLaTeX(note); want != got {
+			t.Errorf("%q: expecting decoded value %q but got %q", value, want, got)
+		}
+	}
+}
+
+// Tests that a backslash before the closing brace does not escape it.
+func TestFormatTrailingBackslash(t *testing.T) {
+	input := "@misc{a,\n  file = {C:\\dir\\},\n  note = {N}\n}\n"
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := `C:\dir\`, bib.Entries[0].Fields["file"].String(); want != got {
+		t.Errorf("Expecting file %q but got %q", want, got)
+	}
+	if want, got := "N", bib.Entries[0].Fields["note"].String(); want != got {
+		t.Errorf("Expecting note %q but got %q", want, got)
+	}
+	bib2, err := Parse(strings.NewReader(NewFormatter().Format(bib)))
+	if err != nil {
+		t.Fatalf("Cannot parse output: %v", err)
+	}
+	AssertEntryListsEqual(t, bib.Entries, bib2.Entries)
+	if want, got := "  file = {C:\\dir\\}", bib.Entries[0].String(); !strings.Contains(got, want) {
+		t.Errorf("Expecting String to contain %q but got %q", want, got)
+	}
+}
+
+func TestFormatTitleCase(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a,
+  title = {the Design of {BibTeX} in \LaTeX: A Tour of {NASA}-style files},
+  journal = {the journal},
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for style, expected := range map[TitleCaseStyle]string{
+		TitleCaseNone:     `{the Design of {BibTeX} in \LaTeX: A Tour of {NASA}-style files}`,
+		TitleCaseSentence: `{The design of {BibTeX} in \LaTeX: A tour of {NASA}-style files}`,
+		TitleCaseTitle:    `{The Design of {BibTeX} in \LaTeX: A Tour of {NASA}-Style Files}`,
+	} {
+		f := NewFormatter()
+		f.TitleCase = style
+		s := f.Format(bib)
+		if !strings.Contains(s, "title   = "+expected+",") {
+			t.Errorf("Style %d: expecting title %s, got:\n%s", style, expected, s)
+		}
+		if !strings.Contains(s, `"the journal"`) {
+			t.Errorf("Style %d: expecting journal to be unchanged, got:\n%s", style, s)
+		}
+	}
+}
+
+func TestChangeCase(t *testing.T) {
+	for input, expected := range map[string]string{
+		"{BibTeX} Is Great":        "{BibTeX} is great",
+		"on iPhone Apps: The Case": "On iphone apps: The case",
+		`\"Uber Alles`:             `\"uber alles`,
+	} {
+		if want, got := expected, changeCase(input, TitleCaseSentence); want != got {
+			t.Errorf("Expecting %q in sentence case to be %q but got %q", input, want, got)
+		}
+	}
+	if want, got := "On Web Apps: The Case of the Web", changeCase("on web apps: the case of the web", TitleCaseTitle); want != got {
+		t.Errorf("Expecting title case %q but got %q", want, got)
+	}
+}
+
+func TestFormatFieldOrder(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a,
+  note = {N},
+  url = {http://example.com},
+  year = 2021,
+  abstract = {A},
+  Pages = {1--2},
+  journal = {J},
+  title = {T},
+  keywords = {k},
+  author = {Doe, Jane},
+  doi = {10.1/x},
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `@article{a,
+    author   = "Doe, Jane",
+    title    = "T",
+    journal  = "J",
+    Pages    = "1--2",
+    year     = 2021,
+    doi      = "10.1/x",
+    url      = "http://example.com",
+    note     = "N",
+    abstract = "A",
+    keywords = "k"
+}
+`
+	if s := NewFormatter().Format(bib); s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+
+	f := NewFormatter()
+	f.FieldOrder = []string{"title", "year"}
+	expected = `@article{a,
+    title    = "T",
+    year     = 2021,
+    note     = "N",
+    url      = "http://example.com",
+    abstract = "A",
+    Pages    = "1--2",
+    journal  = "J",
+    keywords = "k",
+    author   = "Doe, Jane",
+    doi      = "10.1/x"
+}
+`
+	if s := f.Format(bib); s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+}
+
+func TestFormatKeyCase(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@inproceedings{Smith:2020, crossref = {ICSE2020}, ids = {SmithJ20, Smith-A}, title = {Keep Me}}
+@proceedings{ICSE2020, xref = {ICSE}, title = {Proc. ICSE}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `@inproceedings{smith:2020,
+    crossref = "icse2020",
+    ids      = "smithj20, smith-a",
+    title    = "Keep Me"
+}
+@proceedings{icse2020,
+    xref  = "icse",
+    title = "Proc. ICSE"
+}
+`
+	f := &Formatter{Indent: "    ", KeyCase: KeyCaseLower}
+	if s := f.Format(bib); s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+	if s := NewFormatter().Format(bib); !strings.Contains(s, "{Smith:2020,") || !strings.Contains(s, `"ICSE2020"`) {
+		t.Errorf("Expecting cite names to be kept by default, got:\n%s", s)
+	}
+}
+
+func TestFormatUseDateField(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {A}, year = 2020, month = mar, pages = {1--2}}
+@article{b, Year = {2019}, title = {B}}
+@article{c, year = 2021, month = {11}}
+@online{d, date = {2020-05-01}, year = 2020, month = may}
+@misc{e, year = {2020a}, month = jan}
+@misc{f, year = 2018, month = {Spring}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `@article{a,
+    title = "A",
+    pages = "1--2",
+    date  = "2020-03"
+}
+
+@article{b,
+    title = "B",
+    date  = 2019
+}
+
+@article{c,
+    date = "2021-11"
+}
+
+@online{d,
+    year  = 2020,
+    month = "May",
+    date  = "2020-05-01"
+}
+
+@misc{e,
+    year  = "2020a",
+    month = "January"
+}
+
+@misc{f,
+    year  = 2018,
+    month = "Spring"
+}
+`
+	f := NewFormatter()
+	f.UseDateField = true
+	if s := f.Format(bib); s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+}
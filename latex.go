@@ -0,0 +1,372 @@
+package bibtex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// latexAccents maps LaTeX accent commands to Unicode combining characters.
+var latexAccents = map[string]rune{
+	"`":  '\u0300', // grave
+	"'":  '\u0301', // acute
+	"^":  '\u0302', // circumflex
+	"~":  '\u0303', // tilde
+	"=":  '\u0304', // macron
+	"u":  '\u0306', // breve
+	".":  '\u0307', // dot above
+	"\"": '\u0308', // diaeresis
+	"r":  '\u030A', // ring above
+	"H":  '\u030B', // double acute
+	"v":  '\u030C', // caron
+	"d":  '\u0323', // dot below
+	"c":  '\u0327', // cedilla
+	"k":  '\u0328', // ogonek
+	"b":  '\u0331', // macron below
+}
+
+// latexSymbols maps LaTeX commands for special characters to Unicode.
+var latexSymbols = map[string]string{
+	"i": "ı", "j": "ȷ",
+	"o": "ø", "O": "Ø",
+	"l": "ł", "L": "Ł",
+	"ae": "æ", "AE": "Æ",
+	"oe": "œ", "OE": "Œ",
+	"aa": "å", "AA": "Å",
+	"ss": "ß",
+	"&":  "&", "%": "%", "$": "$", "#": "#", "_": "_",
+	"{": "{", "}": "}", "textbraceleft": "{", "textbraceright": "}",
+	" ": " ", // Control space, e.g. Dr.\ Smith.
+}
+
+// latexPlainSymbols maps LaTeX commands without arguments that produce text
+// to plain text, see LaTeXToPlain.
+var latexPlainSymbols = map[string]string{
+	"LaTeX": "LaTeX", "TeX": "TeX", "BibTeX": "BibTeX",
+	"ldots": "…", "dots": "…",
+	"textendash": "–", "textemdash": "—",
+}
+
+// DecodeLaTeX converts LaTeX accents and special characters in s to Unicode,
+// e.g. {\"o} or \"o to ö, \ss to ß, \& to & and a control space "\ " to a
+// space. Dashes written as -- and --- become en and em dashes. Other commands
+// and braces are kept as they are.
+func DecodeLaTeX(s string) string {
+	return decodeLaTeX(s, false)
+}
+
+// LaTeXToPlain renders s, e.g. a note, as plain text. Like DecodeLaTeX it
+// decodes special characters, but also removes grouping braces and other
+// commands, keeping their arguments, e.g. \emph{new} becomes new. Commands
+// without text, e.g. \relax, \protect or \noindent, are dropped. A ~ or a \\
+// line break becomes a space.
+func LaTeXToPlain(s string) string {
+	d := latexDecoder{s: s, stripBraces: true, plain: true}
+	d.decode(false)
+	return norm.NFC.String(d.buf.String())
+}
+
+// decodeLaTeX is DecodeLaTeX, but also removes grouping (protective) braces
+// if stripBraces is set. Other commands are then removed as by LaTeXToPlain,
+// keeping their arguments, e.g. \emph{Go} becomes Go.
+func decodeLaTeX(s string, stripBraces bool) string {
+	d := latexDecoder{s: s, stripBraces: stripBraces}
+	d.decode(false)
+	return norm.NFC.String(d.buf.String())
+}
+
+// stripAccents removes accents (combining marks) from s, e.g. ö becomes o.
+// Other non-ASCII letters, e.g. ß or ø, are kept.
+func stripAccents(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+// transliterations spell the letters that are not accented ASCII letters in
+// ASCII, see Slugify.
+var transliterations = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "ı", "i", "ȷ", "j",
+	"đ", "d", "Đ", "D", "ð", "d", "Ð", "D", "þ", "th", "Þ", "TH",
+)
+
+// Slugify returns title as a URL slug: LaTeX decoded, accents stripped and
+// other letters spelled in ASCII, e.g. ß as ss, lowercase, and with every run
+// of other characters replaced by a single hyphen, e.g. "{\"U}ber {Stra\ss e}:
+// A Tour" becomes uber-strasse-a-tour.
+func Slugify(title string) string {
+	s := transliterations.Replace(stripAccents(decodeLaTeX(title, true)))
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if r > unicode.MaxASCII || !isAlphanum(r) {
+			hyphen = b.Len() > 0
+			continue
+		}
+		if hyphen {
+			b.WriteByte('-')
+			hyphen = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// defaultVerbatimFields are the verbatim fields of entries not parsed with
+// Parser.VerbatimFields set.
+var defaultVerbatimFields = map[string]bool{"url": true, "doi": true, "file": true}
+
+// verbatimSet returns the set of the folded names, or nil if names is nil.
+func verbatimSet(names []string) map[string]bool {
+	if names == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[foldKey(name)] = true
+	}
+	return set
+}
+
+// isVerbatim returns true if the value of the named field is used as it is,
+// see Parser.VerbatimFields.
+func (entry *BibEntry) isVerbatim(name string) bool {
+	if entry.verbatim == nil {
+		return defaultVerbatimFields[foldKey(name)]
+	}
+	return entry.verbatim[foldKey(name)]
+}
+
+// decodeField returns the value of the named field with LaTeX decoded and
+// protective braces removed, or as it is for a verbatim field.
+func (entry *BibEntry) decodeField(name, value string) string {
+	if entry.isVerbatim(name) {
+		return value
+	}
+	return decodeLaTeX(value, true)
+}
+
+// normalizeNFC converts the constant parts of s to Unicode NFC. String var
+// references are left alone.
+func normalizeNFC(s BibString) BibString {
+	switch s := s.(type) {
+	case BibConst:
+		return NewBibConst(norm.NFC.String(string(s)))
+	case *BibComposite:
+		comp := make(BibComposite, len(*s))
+		for i, part := range *s {
+			comp[i] = normalizeNFC(part)
+		}
+		return &comp
+	}
+	return s
+}
+
+// latexDecoder is a single pass LaTeX to Unicode decoder.
+type latexDecoder struct {
+	s           string
+	i           int // Position in s.
+	buf         strings.Builder
+	stripBraces bool
+	plain       bool // Remove other commands, see LaTeXToPlain.
+}
+
+// decode decodes s from the current position, until the end of the current
+// group if inGroup is set.
+func (d *latexDecoder) decode(inGroup bool) {
+	for d.i < len(d.s) {
+		switch ch := d.s[d.i]; {
+		case ch == '\\':
+			d.command()
+		case ch == '{':
+			d.i++
+			if d.isSpecialGroup() {
+				d.decode(true) // Braces around a special character, e.g. {\"o}.
+				continue
+			}
+			if !d.stripBraces {
+				d.buf.WriteByte('{')
+			}
+			d.decode(true)
+			if !d.stripBraces {
+				d.buf.WriteByte('}')
+			}
+		case ch == '}':
+			d.i++
+			if inGroup {
+				return
+			}
+			if !d.stripBraces {
+				d.buf.WriteByte('}') // Unbalanced.
+			}
+		case ch == '~' && d.plain:
+			d.buf.WriteByte(' ')
+			d.i++
+		case strings.HasPrefix(d.s[d.i:], "---"):
+			d.buf.WriteString("—")
+			d.i += 3
+		case strings.HasPrefix(d.s[d.i:], "--"):
+			d.buf.WriteString("–")
+			d.i += 2
+		default:
+			d.buf.WriteByte(ch)
+			d.i++
+		}
+	}
+}
+
+// isSpecialGroup returns true if the group starting at the current position
+// contains only an accent or special character command, e.g. \"o or \ss.
+func (d *latexDecoder) isSpecialGroup() bool {
+	if !strings.HasPrefix(d.s[d.i:], `\`) {
+		return false
+	}
+	name := commandName(d.s[d.i+1:])
+	_, accent := latexAccents[name]
+	_, symbol := latexSymbols[name]
+	return accent || symbol
+}
+
+// command decodes the command at the current position.
+func (d *latexDecoder) command() {
+	name := commandName(d.s[d.i+1:])
+	if name == "" { // Lone backslash at the end.
+		d.buf.WriteByte('\\')
+		d.i++
+		return
+	}
+	start := d.i
+	d.i += 1 + len(name)
+
+	if mark, ok := latexAccents[name]; ok {
+		arg, ok := d.argument(isLetterCommand(name))
+		if !ok {
+			d.buf.WriteString(d.s[start:d.i])
+			return
+		}
+		r, size := utf8.DecodeRuneInString(arg)
+		switch r { // Dotless letters are only used to carry an accent.
+		case 'ı':
+			r = 'i'
+		case 'ȷ':
+			r = 'j'
+		}
+		d.buf.WriteRune(r)
+		d.buf.WriteRune(mark)
+		d.buf.WriteString(arg[size:])
+		return
+	}
+	if sym, ok := latexSymbols[name]; ok {
+		d.buf.WriteString(sym)
+		if isLetterCommand(name) {
+			d.skipTerminator()
+		}
+		return
+	}
+	if !d.plain && !d.stripBraces {
+		d.buf.WriteString(d.s[start:d.i]) // Not a special character.
+		return
+	}
+	if name == "\\" {
+		d.lineBreak()
+		return
+	}
+	if text, ok := latexPlainSymbols[name]; ok {
+		d.buf.WriteString(text)
+	}
+	if isLetterCommand(name) { // Arguments, if any, are decoded as groups.
+		for d.i < len(d.s) && d.s[d.i] == ' ' {
+			d.i++
+		}
+	}
+}
+
+// lineBreak renders a \\ line break, with its optional * and [length], as a
+// space, unless at the start or end of the text or already after a space.
+func (d *latexDecoder) lineBreak() {
+	if d.i < len(d.s) && d.s[d.i] == '*' {
+		d.i++
+	}
+	if strings.HasPrefix(d.s[d.i:], "[") {
+		if end := strings.IndexByte(d.s[d.i:], ']'); end >= 0 {
+			d.i += end + 1
+		}
+	}
+	for d.i < len(d.s) && isWhitespace(rune(d.s[d.i])) {
+		d.i++
+	}
+	if text := d.buf.String(); text != "" && !strings.HasSuffix(text, " ") && d.i < len(d.s) {
+		d.buf.WriteByte(' ')
+	}
+}
+
+// argument reads the (decoded) argument of an accent command, which is either
+// a group or a single character, possibly a special character command.
+func (d *latexDecoder) argument(skipSpace bool) (string, bool) {
+	if skipSpace {
+		for d.i < len(d.s) && d.s[d.i] == ' ' {
+			d.i++
+		}
+	}
+	if d.i >= len(d.s) {
+		return "", false
+	}
+	sub := latexDecoder{s: d.s, i: d.i, stripBraces: true, plain: d.plain}
+	switch d.s[d.i] {
+	case '{':
+		sub.i++
+		sub.decode(true)
+	case '\\':
+		sub.command()
+	case '}':
+		return "", false
+	default:
+		_, size := utf8.DecodeRuneInString(d.s[d.i:])
+		sub.buf.WriteString(d.s[d.i : d.i+size])
+		sub.i += size
+	}
+	d.i = sub.i
+	if sub.buf.Len() == 0 {
+		return "", false
+	}
+	return sub.buf.String(), true
+}
+
+// skipTerminator skips the space or empty group ending a control word.
+func (d *latexDecoder) skipTerminator() {
+	if strings.HasPrefix(d.s[d.i:], "{}") {
+		d.i += 2
+	} else if strings.HasPrefix(d.s[d.i:], " ") {
+		d.i++
+	}
+}
+
+// commandName returns the name of the command at the start of s (following
+// a backslash), i.e. a run of letters or a single non-letter.
+func commandName(s string) string {
+	if s == "" {
+		return ""
+	}
+	end := strings.IndexFunc(s, func(r rune) bool { return r > unicode.MaxASCII || !isAlpha(r) })
+	switch end {
+	case -1:
+		return s
+	case 0:
+		_, size := utf8.DecodeRuneInString(s)
+		return s[:size]
+	}
+	return s[:end]
+}
+
+// isLetterCommand returns true if name is a control word (e.g. \ss), as
+// opposed to a control symbol (e.g. \").
+func isLetterCommand(name string) bool {
+	return isAlpha(rune(name[0]))
+}
@@ -0,0 +1,103 @@
+package bibtex
+
+import (
+	"testing"
+)
+
+func TestDecodeLaTeX(t *testing.T) {
+	for input, expected := range map[string]string{
+		`Schr{\"o}dinger`:             "Schrödinger",
+		`Schr\"odinger`:               "Schrödinger",
+		`Schr\"{o}dinger`:             "Schrödinger",
+		`Fran\c{c}ois and \c cedil`:   "François and çedil",
+		`Stra\ss e`:                   "Straße",
+		`{\ss}`:                       "ß",
+		`Erd\H{o}s and \v{C}ech`:      "Erdős and Čech",
+		`Sm{\o}rgrov \& {\AE}sir`:     "Smørgrov & Æsir",
+		`na\"{\i}ve`:                  "naïve",
+		`50\% \$5 a\_b`:               "50% $5 a_b",
+		`pages 1--2 --- yes`:          "pages 1–2 — yes",
+		`Dr.\ Smith`:                  "Dr. Smith",
+		`\textbf{Hello} {World}`:      `\textbf{Hello} {World}`,
+		`unbalanced } and trailing \`: `unbalanced } and trailing \`,
+	} {
+		if want, got := expected, DecodeLaTeX(input); want != got {
+			t.Errorf("Expecting %q to decode to %q but got %q", input, want, got)
+		}
+	}
+}
+
+func TestDisplayTitle(t *testing.T) {
+	article := NewBibEntry("article", "a")
+	article.AddField("title", NewBibConst(`The {G}\"odel {Theorem}`))
+	article.AddField("journal", NewBibConst("J"))
+
+	inproceedings := NewBibEntry("inproceedings", "b")
+	inproceedings.AddField("title", NewBibConst("{On} Parsing"))
+	inproceedings.AddField("booktitle", NewBibConst("Proc. of Things"))
+
+	incollection := NewBibEntry("incollection", "c")
+	incollection.AddField("booktitle", NewBibConst(`Collected {\'E}ssays`))
+
+	book := NewBibEntry("book", "d")
+	book.AddField("title", NewBibConst(`The \emph{Go} Book`))
+
+	misc := NewBibEntry("misc", "e")
+	misc.AddField("title", NewBibConst(`\textbf{Bold} and \url{http://x}`))
+
+	for entry, expected := range map[*BibEntry]string{
+		article:       "The Gödel Theorem",
+		inproceedings: "On Parsing",
+		incollection:  "Collected Éssays",
+		book:          "The Go Book",
+		misc:          "Bold and http://x",
+	} {
+		if want, got := expected, entry.DisplayTitle(); want != got {
+			t.Errorf("Expecting %s title %q but got %q", entry.Type, want, got)
+		}
+	}
+}
+
+func TestLaTeXToPlain(t *testing.T) {
+	for input, expected := range map[string]string{
+		`See the \emph{revised} version by G{\"o}del`:           "See the revised version by Gödel",
+		`{\em Emphasis} and \textbf {bold}`:                     "Emphasis and bold",
+		`Typeset with \LaTeX, pp.~1--2 \ldots`:                  "Typeset with LaTeX, pp. 1–2 …",
+		`\url{http://example.com/a\_b}`:                         "http://example.com/a_b",
+		`Caf\'{e} {\ss}`:                                        "Café ß",
+		`\noindent A\relax{} study of \protect\emph{G{\"o}del}`: "A study of Gödel",
+		`Line one\\line two \\ line three\\*[2pt] four\\`:       "Line one line two line three four",
+		`Dr.~Smith~and~Co.`:                                     "Dr. Smith and Co.",
+		`Dr.\ Smith et al.\ wrote`:                              "Dr. Smith et al. wrote",
+	} {
+		if want, got := expected, LaTeXToPlain(input); want != got {
+			t.Errorf("Expecting %q to render as %q but got %q", input, want, got)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for title, expected := range map[string]string{
+		`{\"U}ber {Stra\ss e}: A Tour`:               "uber-strasse-a-tour",
+		"Caf\u00e9 Society -- The \"Best\" of 2020!": "cafe-society-the-best-of-2020",
+		`The {BibTeX} Book, 2nd ed.`:                 "the-bibtex-book-2nd-ed",
+		`{\AE}sir and Sm{\o}rgrov`:                   "aesir-and-smorgrov",
+		`  ...  `:                                    "",
+	} {
+		if want, got := expected, Slugify(title); want != got {
+			t.Errorf("Expecting %q to slugify to %q but got %q", title, want, got)
+		}
+	}
+}
+
+func TestDisplayNote(t *testing.T) {
+	entry := NewBibEntry("misc", "a")
+	entry.AddField("note", NewBibConst(`Translated by J. M{\"u}ller, \emph{second} edition`))
+	entry.AddField("annote", NewBibConst(`\textit{Caf\'e}`))
+	if want, got := "Translated by J. Müller, second edition", entry.DisplayNote(); want != got {
+		t.Errorf("Expecting note %q but got %q", want, got)
+	}
+	if want, got := "Café", entry.DisplayAnnote(); want != got {
+		t.Errorf("Expecting annote %q but got %q", want, got)
+	}
+}
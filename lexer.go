@@ -6,23 +6,141 @@ import "io"
 
 // lexer for bibtex.
 type lexer struct {
-	scanner *scanner
-	Errors  chan error
+	scanner  *scanner
+	parser   *Parser
+	bib      *BibTex         // BibTex being parsed.
+	seen     map[string]bool // Fields seen in the current entry.
+	comment  bool            // Parsing a @comment.
+	inEntry  bool            // Between an @ and the token closing its entry.
+	verbatim map[string]bool // Folded Parser.VerbatimFields, if set.
+	closer   token           // Token closing the current entry.
+	pending  token           // Token to return before scanning further.
+	raw      string          // Source text of the last entry, if kept.
+	entries  int             // Number of entries started.
+	Errors   chan error
 }
 
 // newLexer returns a new yacc-compatible lexer.
-func newLexer(r io.Reader) *lexer {
-	return &lexer{scanner: newScanner(r), Errors: make(chan error, 1)}
+func newLexer(r io.Reader, p *Parser) *lexer {
+	l := &lexer{
+		scanner: newScanner(r),
+		parser:  p,
+		seen:    make(map[string]bool),
+		Errors:  make(chan error, 1),
+	}
+	l.scanner.maxValue = p.MaxValueBytes
+	return l
+}
+
+// reset discards the lexer state and makes it read from r.
+func (l *lexer) reset(r io.Reader) {
+	l.scanner.reset(r)
+	l.bib = nil
+	l.comment = false
+	l.inEntry = false
+	l.closer, l.pending = 0, 0
+	l.raw = ""
+	l.entries = 0
+	l.scanner.maxValue = l.parser.MaxValueBytes
+	select {
+	case <-l.Errors:
+	default:
+	}
 }
 
 // Lex is provided for yacc-compatible parser.
 func (l *lexer) Lex(yylval *bibtexSymType) int {
+	if len(l.Errors) > 0 {
+		return 0 // Stop at the first error.
+	}
+	if token := l.pending; token != 0 {
+		l.pending = 0
+		l.inEntry = false
+		l.endRaw()
+		yylval.strval = ""
+		yylval.pos = l.scanner.pos
+		return int(token)
+	}
+	if !l.inEntry {
+		l.scanner.skipToEntry() // Text outside entries is ignored, as by BibTeX.
+	}
 	token, strval := l.scanner.Scan()
+	if l.scanner.tooLong {
+		l.setError(&LimitExceededError{Pos: l.scanner.start, Limit: "MaxValueBytes", Max: l.parser.MaxValueBytes})
+		return 0
+	}
+	switch token {
+	case tATSIGN:
+		l.inEntry = true
+		l.entries++
+		if max := l.parser.MaxEntries; max > 0 && l.entries > max {
+			l.setError(&LimitExceededError{Pos: l.scanner.start, Limit: "MaxEntries", Max: max})
+			return 0
+		}
+		if l.parser.KeepRawSource {
+			l.scanner.startRecording(strval)
+		}
+	case l.closer:
+		l.inEntry = false
+		l.endRaw()
+	case tCOMMENT:
+		l.comment = true
+	case tLBRACE:
+		l.closer = tRBRACE
+	case tLPAREN:
+		l.closer = tRPAREN
+	}
 	yylval.strval = strval
+	yylval.delim = l.scanner.delim
+	yylval.pos = l.scanner.start
+	if l.scanner.unterminated {
+		l.unterminated()
+	}
 	return int(token)
 }
 
+// endRaw keeps the source text recorded for the entry just closed.
+func (l *lexer) endRaw() {
+	if l.scanner.record {
+		l.raw = l.scanner.recorded.String()
+		l.scanner.record = false
+	}
+}
+
+// unterminated handles a quoted string that runs into the next entry. It is
+// an error unless the parser recovers from it, by keeping the string scanned
+// and closing the current entry, with a warning.
+func (l *lexer) unterminated() {
+	err := &ErrParse{Pos: l.scanner.start, Err: ErrUnterminatedString.Error()}
+	if !l.parser.Recover {
+		l.setError(err)
+		return
+	}
+	l.warn(err)
+	l.pending = l.closer
+	l.scanner.parseField = false
+}
+
+// warn records err as a warning, and a diagnostic.
+func (l *lexer) warn(err *ErrParse) {
+	l.parser.Warnings = append(l.parser.Warnings, err)
+	l.diagnose(SeverityWarning, err)
+}
+
+// diagnose records err as a diagnostic of the given severity.
+func (l *lexer) diagnose(severity Severity, err *ErrParse) {
+	l.parser.Diagnostics = append(l.parser.Diagnostics, Diagnostic{Pos: err.Pos, Severity: severity, Err: err})
+}
+
 // Error handles error.
 func (l *lexer) Error(err string) {
-	l.Errors <- &ErrParse{Err: err, Pos: l.scanner.pos}
+	l.setError(&ErrParse{Err: err, Pos: l.scanner.pos})
+}
+
+// setError records err unless an earlier error has already been recorded.
+func (l *lexer) setError(err error) {
+	select {
+	case l.Errors <- err:
+	default:
+	}
 }
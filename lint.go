@@ -0,0 +1,207 @@
+package bibtex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Lint is a warning about a suspicious field value in a BibTeX entry.
+type Lint struct {
+	Field   string // Name of the field.
+	Message string // Description of the problem.
+}
+
+func (l Lint) String() string {
+	return fmt.Sprintf("%s: %s", l.Field, l.Message)
+}
+
+// Lint checks the fields of entry for values that are valid but most likely
+// a mistake, i.e. empty, whitespace-only or over-protected (wrapped in more
+// than one pair of braces) values.
+func (entry *BibEntry) Lint() []Lint {
+	keys := entry.sortedFieldNames()
+
+	var lints []Lint
+	for _, key := range keys {
+		value := entry.Fields[key].String()
+		switch {
+		case value == "":
+			lints = append(lints, Lint{Field: key, Message: "empty value"})
+		case strings.TrimSpace(value) == "":
+			lints = append(lints, Lint{Field: key, Message: "whitespace-only value"})
+		case wrappingBraces(value) > 1:
+			lints = append(lints, Lint{Field: key, Message: "value is over-protected by braces"})
+		}
+	}
+	return lints
+}
+
+var (
+	// htmlEntity matches HTML character references, e.g. &amp; or &#39;.
+	htmlEntity = regexp.MustCompile(`&(?:[a-zA-Z]+|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+	// doubleEscapedAccent matches an accent command after an escaped
+	// backslash, e.g. \\"o for \"o.
+	doubleEscapedAccent = regexp.MustCompile(`\\\\(?:[` + "`" + `'^"~=.]|[uvHckdbr]\{)`)
+	// mojibake matches UTF-8 text that was decoded as Latin-1 or Windows-1252,
+	// e.g. Ã© for é, or a replacement character for an invalid byte.
+	mojibake = regexp.MustCompile(`[ÂÃ][\x{80}-\x{BF}€‚ƒ„…†‡ˆ‰Š‹ŒŽ‘’“”•–—˜™š›œžŸ]|â€|\x{FFFD}`)
+)
+
+// DetectEncodingIssues checks the fields of entry for signs of a broken
+// export: HTML entities, doubly escaped accent commands and mojibake. It
+// returns a "field: problem" description for each, ordered by field.
+func (entry *BibEntry) DetectEncodingIssues() []string {
+	keys := entry.sortedFieldNames()
+
+	var issues []string
+	for _, key := range keys {
+		value := entry.Fields[key].String()
+		if m := htmlEntity.FindString(value); m != "" {
+			issues = append(issues, Lint{Field: key, Message: "HTML entity " + m}.String())
+		}
+		if m := doubleEscapedAccent.FindString(value); m != "" {
+			issues = append(issues, Lint{Field: key, Message: "doubly escaped accent " + m}.String())
+		}
+		if m := mojibake.FindString(value); m != "" {
+			issues = append(issues, Lint{Field: key, Message: "mojibake " + m}.String())
+		}
+	}
+	return issues
+}
+
+// wrappingBraces returns the number of brace pairs that enclose the whole of s.
+func wrappingBraces(s string) int {
+	n := 0
+	for len(s) >= 2 && s[0] == '{' && s[len(s)-1] == '}' && matchingBrace(s) == len(s)-1 {
+		s = s[1 : len(s)-1]
+		n++
+	}
+	return n
+}
+
+// matchingBrace returns the index of the brace closing the one at s[0],
+// or -1 if it is unbalanced.
+func matchingBrace(s string) int {
+	depth := 0
+	for i, ch := range s {
+		switch ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// typography lists the typographic characters that LaTeX renders poorly when
+// typed directly, with their description and LaTeX replacement.
+var typography = []struct {
+	char, name, latex string
+}{
+	{"“", "curly quote", "``"},
+	{"”", "curly quote", "''"},
+	{"‘", "curly quote", "`"},
+	{"’", "curly quote", "'"},
+	{"—", "em dash", "---"},
+	{"–", "en dash", "--"},
+}
+
+// typographyFixer replaces the typography characters by their LaTeX forms.
+var typographyFixer = func() *strings.Replacer {
+	var pairs []string
+	for _, t := range typography {
+		pairs = append(pairs, t.char, t.latex)
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// CheckTypography checks the fields of entry for typographic characters, e.g.
+// from copy and paste, that are better written as LaTeX: curly quotes and en
+// or em dashes. It returns a "field: problem" description for each, ordered
+// by field. Verbatim fields, e.g. url (see Parser.VerbatimFields), are
+// skipped. See FixTypography.
+func (entry *BibEntry) CheckTypography() []string {
+	var issues []string
+	for _, key := range entry.sortedFieldNames() {
+		if entry.isVerbatim(key) {
+			continue
+		}
+		value := entry.Fields[key].String()
+		reported := map[string]bool{}
+		for _, t := range typography {
+			if strings.Contains(value, t.char) && !reported[t.name] {
+				reported[t.name] = true
+				issues = append(issues, Lint{Field: key, Message: t.name + " " + t.char}.String())
+			}
+		}
+	}
+	return issues
+}
+
+// FixTypography replaces the characters reported by CheckTypography by their
+// LaTeX forms, e.g. a left curly quote by two backticks and an em dash by ---.
+// String var references and verbatim fields, e.g. url (see
+// Parser.VerbatimFields), are left alone.
+func (entry *BibEntry) FixTypography() {
+	for _, name := range entry.fieldNames() {
+		if !entry.isVerbatim(name) {
+			entry.Fields[name] = fixTypography(entry.Fields[name])
+		}
+	}
+}
+
+func fixTypography(s BibString) BibString {
+	switch s := s.(type) {
+	case BibConst:
+		return NewBibConst(typographyFixer.Replace(string(s)))
+	case *BibComposite:
+		comp := make(BibComposite, len(*s))
+		for i, part := range *s {
+			comp[i] = fixTypography(part)
+		}
+		return &comp
+	}
+	return s
+}
+
+// nameSuffixes are the name suffixes of the "von Last, Jr, First" form.
+var nameSuffixes = map[string]bool{
+	"jr": true, "jr.": true, "sr": true, "sr.": true,
+	"ii": true, "iii": true, "iv": true,
+}
+
+// SuspiciousAuthorList returns true if the author field of entry looks like a
+// list of names separated by commas instead of "and", e.g.
+// {Smith, John, Doe, Jane}: it has more than one comma outside of braces, no
+// "and", and is not a single "von Last, Jr, First" name. This is a heuristic
+// for review; the field is not changed.
+func (entry *BibEntry) SuspiciousAuthorList() bool {
+	return suspiciousAuthorList(entry.firstField("author"))
+}
+
+func suspiciousAuthorList(author string) bool {
+	var commas int
+	var parts [][]string
+	part := []string{}
+	for _, word := range nameWords(author) {
+		switch {
+		case strings.EqualFold(word, "and"):
+			return false
+		case word == ",":
+			commas++
+			parts = append(parts, part)
+			part = []string{}
+		default:
+			part = append(part, word)
+		}
+	}
+	if commas < 2 {
+		return false
+	}
+	return commas > 2 || !nameSuffixes[strings.ToLower(join(parts[1]))]
+}
@@ -0,0 +1,150 @@
+package bibtex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{abcd,
+  title = {{{fully braced}}},
+  journal = {{Protected} Journal},
+  note = {},
+  howpublished = {   },
+  year = 2019,
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lints := bib.Entries[0].Lint()
+	expected := []string{
+		"howpublished: whitespace-only value",
+		"note: empty value",
+		"title: value is over-protected by braces",
+	}
+	if want, got := len(expected), len(lints); want != got {
+		t.Fatalf("Expecting %d lints but got %d: %v", want, got, lints)
+	}
+	for i, lint := range lints {
+		if want, got := expected[i], lint.String(); want != got {
+			t.Errorf("Expecting lint %q but got %q", want, got)
+		}
+	}
+}
+
+func TestDetectEncodingIssues(t *testing.T) {
+	entry := NewBibEntry("article", "abcd")
+	entry.AddField("title", NewBibConst(`Smith &amp; Jones`))
+	entry.AddField("author", NewBibConst(`G{\\"o}del, Kurt`))
+	entry.AddField("journal", NewBibConst("Journal of Caf\u00c3\u00a9s \u00e2\u20ac\u201d a review"))
+	entry.AddField("note", NewBibConst(`Clean \"o and {\'e}, 50\% \\ next line`))
+	expected := []string{
+		`author: doubly escaped accent \\"`,
+		"journal: mojibake \u00c3\u00a9",
+		`title: HTML entity &amp;`,
+	}
+	issues := entry.DetectEncodingIssues()
+	if want, got := len(expected), len(issues); want != got {
+		t.Fatalf("Expecting %d issues but got %d: %q", want, got, issues)
+	}
+	for i, issue := range issues {
+		if want, got := expected[i], issue; want != got {
+			t.Errorf("Expecting issue %d to be %q but got %q", i, want, got)
+		}
+	}
+}
+
+func TestTypography(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{pub = "O’Reilly"}
+@book{a,
+  title = {“Curly” quotes — and ‘more’ “quotes”},
+  pages = {1–10},
+  publisher = pub # { – Media},
+  note = {Plain 'quotes' --- fine},
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	expected := []string{
+		"pages: en dash –",
+		"publisher: curly quote ’",
+		"publisher: en dash –",
+		"title: curly quote “",
+		"title: em dash —",
+	}
+	issues := entry.CheckTypography()
+	if want, got := len(expected), len(issues); want != got {
+		t.Fatalf("Expecting %d issues but got %d: %q", want, got, issues)
+	}
+	for i, issue := range issues {
+		if want, got := expected[i], issue; want != got {
+			t.Errorf("Expecting issue %d to be %q but got %q", i, want, got)
+		}
+	}
+
+	entry.FixTypography()
+	for field, expected := range map[string]string{
+		"title":     "``Curly'' quotes --- and `more' ``quotes''",
+		"pages":     "1--10",
+		"publisher": "O’Reilly -- Media",
+		"note":      "Plain 'quotes' --- fine",
+	} {
+		if want, got := expected, entry.Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+	if want, got := "pub # { -- Media}", entry.Fields["publisher"].RawString(); want != got {
+		t.Errorf("Expecting string var reference to be kept, %q but got %q", want, got)
+	}
+}
+
+// Tests that verbatim fields, like a file path, are neither reported nor
+// changed.
+func TestTypographyVerbatim(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@misc{a,
+  title = {John’s notes},
+  File = {:John’s notes – draft.pdf:PDF},
+  url = {https://example.com/John’s},
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	if want, got := []string{"title: curly quote ’"}, entry.CheckTypography(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Expecting issues %q but got %q", want, got)
+	}
+	entry.FixTypography()
+	expected := []Pair{
+		{"title", "John's notes"},
+		{"File", ":John’s notes – draft.pdf:PDF"},
+		{"url", "https://example.com/John’s"},
+	}
+	if got := entry.Pairs(false); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting fields %v but got %v", expected, got)
+	}
+}
+
+func TestSuspiciousAuthorList(t *testing.T) {
+	for author, expected := range map[string]bool{
+		"Smith, John, Doe, Jane":            true,
+		"Smith, John, Doe":                  true,
+		"Smith, John and Doe, Jane":         false,
+		"John Smith and Jane Doe":           false,
+		"Smith, John":                       false,
+		"King, Jr., Martin Luther":          false,
+		"{Barnes, Noble, and Co.}, Ltd":     false,
+		"{Smith, Jones and Partners}, Inc.": false,
+	} {
+		entry := NewBibEntry("article", "a")
+		entry.AddField("author", NewBibConst(author))
+		if want, got := expected, entry.SuspiciousAuthorList(); want != got {
+			t.Errorf("Expecting %q to be suspicious: %t but got %t", author, want, got)
+		}
+	}
+	if NewBibEntry("article", "a").SuspiciousAuthorList() {
+		t.Errorf("Expecting no author not to be suspicious")
+	}
+}
@@ -0,0 +1,343 @@
+package bibtex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Name is a personal name split into the parts used by BibTeX.
+type Name struct {
+	First string // Given names, e.g. "Donald E."
+	Von   string // Particles, e.g. "van der"
+	Last  string // Family name.
+	Jr    string // Suffix, e.g. "Jr."
+
+	// IsCorporate is set for a name written entirely in braces, e.g.
+	// {IBM Corporation}, which is kept as it is in Last.
+	IsCorporate bool
+}
+
+// String returns the name in BibTeX "von Last, Jr, First" form.
+func (n Name) String() string {
+	s := n.Last
+	if n.Von != "" {
+		s = n.Von + " " + s
+	}
+	if n.Jr != "" {
+		s += ", " + n.Jr
+	}
+	if n.First != "" {
+		s += ", " + n.First
+	}
+	return s
+}
+
+// NameStyle is a display form of a Name, see Name.Format.
+type NameStyle int
+
+const (
+	// NameLastInitials is "von Last, F. M., Jr", e.g. "Smith, J.".
+	NameLastInitials NameStyle = iota
+	// NameFirstLast is "First von Last, Jr", e.g. "John Smith".
+	NameFirstLast
+	// NameInitialsLast is "F. M. von Last, Jr", e.g. "J. Smith".
+	NameInitialsLast
+)
+
+// Format returns the name in the given display style. Given names are
+// abbreviated to initials where the style requires it, keeping hyphens in
+// compound names, e.g. "Jean-Baptiste" becomes "J.-B.".
+func (n Name) Format(style NameStyle) string {
+	last := n.Last
+	if n.Von != "" {
+		last = n.Von + " " + last
+	}
+	first := n.First
+	if style != NameFirstLast {
+		first = initials(first)
+	}
+
+	s := last
+	switch {
+	case first == "":
+	case style == NameLastInitials:
+		s += ", " + first
+	default:
+		s = first + " " + last
+	}
+	if n.Jr != "" {
+		s += ", " + n.Jr
+	}
+	return s
+}
+
+// initials abbreviates each of the given names to its initial.
+func initials(first string) string {
+	var words []string
+	for _, word := range strings.Fields(first) {
+		var parts []string
+		for _, part := range strings.Split(word, "-") {
+			if i := initial(part); i != "" {
+				parts = append(parts, i+".")
+			}
+		}
+		if len(parts) > 0 {
+			words = append(words, strings.Join(parts, "-"))
+		}
+	}
+	return join(words)
+}
+
+// initial returns the first letter of word outside of braces. A brace group
+// starting with a control sequence (e.g. {\"O}) is returned as a whole.
+func initial(word string) string {
+	for i := 0; i < len(word); {
+		ch, size := utf8.DecodeRuneInString(word[i:])
+		switch {
+		case ch == '{' && strings.HasPrefix(word[i+1:], `\`):
+			if end := matchingBrace(word[i:]); end >= 0 {
+				return word[i : i+end+1]
+			}
+			return word[i:]
+		case unicode.IsLetter(ch):
+			return word[i : i+size]
+		}
+		i += size
+	}
+	return ""
+}
+
+// NameList is a parsed list of names, e.g. the value of an author field.
+type NameList struct {
+	Names []Name
+
+	// HasOthers is set if the list ends with "and others" (or "et al."),
+	// meaning there are more names than listed.
+	HasOthers bool
+}
+
+// ParseNames parses a list of names separated by "and", as in the author and
+// editor fields. Each name may be written as "First von Last",
+// "von Last, First" or "von Last, Jr, First". Text in braces is never split,
+// and a name that is entirely in braces is a corporate name.
+//
+// Names may also be given in the BibLaTeX extended name format, e.g.
+// "family=Smith, given=John", in which case the parts are used as they are.
+//
+// A final name "others", or a trailing "et al." after the last name, sets
+// HasOthers instead of being parsed as a name.
+func ParseNames(s string) *NameList {
+	list := &NameList{}
+	var name []string
+	for _, word := range append(nameWords(s), "and") { // Final "and" ends the list.
+		if !strings.EqualFold(word, "and") {
+			name = append(name, word)
+			continue
+		}
+		if len(name) == 0 {
+			continue
+		}
+		if len(name) == 1 && name[0] == "others" {
+			list.HasOthers = true
+		} else if len(name) == 1 && isCorporateName(name[0]) {
+			list.Names = append(list.Names, Name{Last: name[0], IsCorporate: true})
+		} else if keyed, ok := parseKeyedName(name); ok {
+			list.Names = append(list.Names, keyed)
+		} else if n, etAl := trimEtAl(name); len(n) > 0 {
+			list.Names = append(list.Names, parseName(n))
+			list.HasOthers = list.HasOthers || etAl
+		}
+		name = nil
+	}
+	return list
+}
+
+// nameWords splits s into words at whitespace (and ~) outside of braces.
+// Commas outside of braces are returned as separate words.
+func nameWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	depth := 0
+	endWord := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, ch := range s {
+		switch {
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+		case depth == 0 && (unicode.IsSpace(ch) || ch == '~'):
+			endWord()
+			continue
+		case depth == 0 && ch == ',':
+			endWord()
+			words = append(words, ",")
+			continue
+		}
+		word.WriteRune(ch)
+	}
+	endWord()
+	return words
+}
+
+// trimEtAl removes a trailing "et al." from the words of a name.
+func trimEtAl(words []string) ([]string, bool) {
+	n := len(words)
+	if n < 2 || words[n-2] != "et" || (words[n-1] != "al" && words[n-1] != "al.") {
+		return words, false
+	}
+	words = words[:n-2]
+	for len(words) > 0 && words[len(words)-1] == "," {
+		words = words[:len(words)-1]
+	}
+	return words, true
+}
+
+// isCorporateName returns true if word is a single brace group, other than a
+// special character (e.g. {\"O}).
+func isCorporateName(word string) bool {
+	return wrappingBraces(word) > 0 && !strings.HasPrefix(word[1:], `\`)
+}
+
+// keyedNameParts maps the keys of the BibLaTeX extended name format to the
+// parts of a Name.
+var keyedNameParts = map[string]func(n *Name) *string{
+	"family": func(n *Name) *string { return &n.Last },
+	"given":  func(n *Name) *string { return &n.First },
+	"prefix": func(n *Name) *string { return &n.Von },
+	"suffix": func(n *Name) *string { return &n.Jr },
+}
+
+// parseKeyedName parses the words of a name in the BibLaTeX extended name
+// format, e.g. "family=Smith, given=John". Other keys (e.g. useprefix) are
+// ignored. It returns false if the name is not in this format.
+func parseKeyedName(words []string) (Name, bool) {
+	var n Name
+	found := false
+	start := 0
+	for i := 0; i <= len(words); i++ {
+		if i < len(words) && words[i] != "," {
+			continue
+		}
+		part := join(words[start:i])
+		start = i + 1
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			return Name{}, false
+		}
+		key, val := strings.ToLower(strings.TrimSpace(part[:eq])), strings.TrimSpace(part[eq+1:])
+		if wrappingBraces(val) > 0 {
+			val = val[1 : len(val)-1]
+		}
+		if field, ok := keyedNameParts[key]; ok {
+			*field(&n) = val
+			found = true
+		}
+	}
+	return n, found
+}
+
+// parseName parses the words of a single name.
+func parseName(words []string) Name {
+	var parts [][]string
+	part := []string{}
+	for _, word := range words {
+		if word == "," {
+			parts = append(parts, part)
+			part = []string{}
+			continue
+		}
+		part = append(part, word)
+	}
+	parts = append(parts, part)
+
+	switch len(parts) {
+	case 1: // First von Last
+		w := parts[0]
+		last := len(w) - 1
+		vonStart, vonEnd := -1, -1
+		for i := 0; i < last; i++ {
+			if isLowerWord(w[i]) {
+				if vonStart < 0 {
+					vonStart = i
+				}
+				vonEnd = i
+			}
+		}
+		if vonStart < 0 {
+			return Name{First: join(w[:last]), Last: w[last]}
+		}
+		return Name{First: join(w[:vonStart]), Von: join(w[vonStart : vonEnd+1]), Last: join(w[vonEnd+1:])}
+	case 2: // von Last, First
+		von, last := splitVonLast(parts[0])
+		return Name{First: join(parts[1]), Von: von, Last: last}
+	default: // von Last, Jr, First
+		von, last := splitVonLast(parts[0])
+		var first []string
+		for _, p := range parts[2:] {
+			first = append(first, p...)
+		}
+		return Name{First: join(first), Von: von, Last: last, Jr: join(parts[1])}
+	}
+}
+
+// splitVonLast splits "von Last" at the last lowercase word, the final word
+// always being part of the last name.
+func splitVonLast(w []string) (von, last string) {
+	for i := len(w) - 2; i >= 0; i-- {
+		if isLowerWord(w[i]) {
+			return join(w[:i+1]), join(w[i+1:])
+		}
+	}
+	return "", join(w)
+}
+
+// isLowerWord returns true if the first letter of word outside of braces is
+// lowercase. A brace group starting with a control sequence (e.g. {\"o}) is
+// a special character and counts as its letter; other brace groups are
+// skipped.
+func isLowerWord(word string) bool {
+	depth := 0
+	for i := 0; i < len(word); {
+		ch, size := utf8.DecodeRuneInString(word[i:])
+		switch {
+		case ch == '{' && depth == 0 && strings.HasPrefix(word[i+1:], `\`):
+			for _, c := range skipControlSequence(word[i+2:]) {
+				if unicode.IsLetter(c) {
+					return unicode.IsLower(c)
+				}
+			}
+			return false
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+		case depth == 0 && unicode.IsLetter(ch):
+			return unicode.IsLower(ch)
+		}
+		i += size
+	}
+	return false
+}
+
+// skipControlSequence returns s without the name of a leading control
+// sequence, unless it is the only letter (e.g. for \o or \ss).
+func skipControlSequence(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	switch {
+	case end == 0: // Control symbol, e.g. \"
+		return s[1:]
+	case end < 0 || strings.IndexFunc(s[end:], unicode.IsLetter) < 0:
+		return s
+	}
+	return s[end:]
+}
+
+func join(words []string) string {
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,108 @@
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+func assertNames(t *testing.T, s string, expected []Name, hasOthers bool) {
+	t.Helper()
+	list := ParseNames(s)
+	if want, got := hasOthers, list.HasOthers; want != got {
+		t.Errorf("%q: expecting HasOthers %t but got %t", s, want, got)
+	}
+	if want, got := len(expected), len(list.Names); want != got {
+		t.Fatalf("%q: expecting %d names but got %d: %#v", s, want, got, list.Names)
+	}
+	for i := range expected {
+		if want, got := expected[i], list.Names[i]; want != got {
+			t.Errorf("%q: expecting name %d to be %#v but got %#v", s, i, want, got)
+		}
+	}
+}
+
+func TestParseNames(t *testing.T) {
+	assertNames(t, "Donald E. Knuth", []Name{{First: "Donald E.", Last: "Knuth"}}, false)
+	assertNames(t, "Doe, John and Jane Doe", []Name{
+		{First: "John", Last: "Doe"},
+		{First: "Jane", Last: "Doe"},
+	}, false)
+	assertNames(t, "Ludwig van Beethoven", []Name{{First: "Ludwig", Von: "van", Last: "Beethoven"}}, false)
+	assertNames(t, "van der Berg, Jan", []Name{{First: "Jan", Von: "van der", Last: "Berg"}}, false)
+	assertNames(t, "King, Jr, Martin Luther", []Name{{First: "Martin Luther", Last: "King", Jr: "Jr"}}, false)
+	assertNames(t, `Kurt G{\"o}del`, []Name{{First: "Kurt", Last: `G{\"o}del`}}, false)
+}
+
+func TestParseNamesOthers(t *testing.T) {
+	assertNames(t, "Smith, J. and others", []Name{{First: "J.", Last: "Smith"}}, true)
+	assertNames(t, "Smith et al.", []Name{{Last: "Smith"}}, true)
+	assertNames(t, "Smith, J. et al", []Name{{First: "J.", Last: "Smith"}}, true)
+	assertNames(t, "Smith, J. and Doe, J., et al.", []Name{
+		{First: "J.", Last: "Smith"},
+		{First: "J.", Last: "Doe"},
+	}, true)
+
+	// Surnames containing "al" are not et al.
+	assertNames(t, "Ahmed al Rashid", []Name{{First: "Ahmed", Von: "al", Last: "Rashid"}}, false)
+	assertNames(t, "Alvarez, V. and Ibn al-Haytham", []Name{
+		{First: "V.", Last: "Alvarez"},
+		{First: "Ibn", Last: "al-Haytham"},
+	}, false)
+}
+
+func TestNameFormat(t *testing.T) {
+	for _, test := range []struct {
+		name                               Name
+		lastInitials, firstLast, initsLast string
+	}{
+		{Name{First: "John", Last: "Smith"}, "Smith, J.", "John Smith", "J. Smith"},
+		{Name{First: "Donald E.", Last: "Knuth"}, "Knuth, D. E.", "Donald E. Knuth", "D. E. Knuth"},
+		{Name{First: "Jean-Baptiste", Last: "Say"}, "Say, J.-B.", "Jean-Baptiste Say", "J.-B. Say"},
+		{Name{First: "Ludwig", Von: "van", Last: "Beethoven"}, "van Beethoven, L.", "Ludwig van Beethoven", "L. van Beethoven"},
+		{Name{First: "Martin Luther", Last: "King", Jr: "Jr"}, "King, M. L., Jr", "Martin Luther King, Jr", "M. L. King, Jr"},
+		{Name{First: `{\"O}mer`, Last: "Yilmaz"}, `Yilmaz, {\"O}.`, `{\"O}mer Yilmaz`, `{\"O}. Yilmaz`},
+		{Name{Last: "Aristotle"}, "Aristotle", "Aristotle", "Aristotle"},
+	} {
+		for style, expected := range map[NameStyle]string{
+			NameLastInitials: test.lastInitials,
+			NameFirstLast:    test.firstLast,
+			NameInitialsLast: test.initsLast,
+		} {
+			if want, got := expected, test.name.Format(style); want != got {
+				t.Errorf("%#v: expecting style %d to be %q but got %q", test.name, style, want, got)
+			}
+		}
+	}
+}
+
+func TestParseNamesBracedAnd(t *testing.T) {
+	assertNames(t, "Barnes {and} Noble", []Name{{First: "Barnes {and}", Last: "Noble"}}, false)
+	assertNames(t, "{Barnes and Noble} and Doe, John", []Name{
+		{Last: "{Barnes and Noble}", IsCorporate: true},
+		{First: "John", Last: "Doe"},
+	}, false)
+}
+
+func TestParseNamesKeyed(t *testing.T) {
+	assertNames(t, "family=Smith, given=John and Doe, Jane", []Name{
+		{First: "John", Last: "Smith"},
+		{First: "Jane", Last: "Doe"},
+	}, false)
+	assertNames(t, "given={Jean Paul}, prefix=van, family={Gogh}, suffix=Jr, useprefix=true and others", []Name{
+		{First: "Jean Paul", Von: "van", Last: "Gogh", Jr: "Jr"},
+	}, true)
+	assertNames(t, "{Barnes=Noble}", []Name{{Last: "{Barnes=Noble}", IsCorporate: true}}, false)
+}
+
+func TestParseNamesCorporate(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@techreport{a, author = {{IBM Corporation}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNames(t, bib.Entries[0].Fields["author"].String(), []Name{{Last: "{IBM Corporation}", IsCorporate: true}}, false)
+	assertNames(t, `{World Health Organization} and Smith, John and {\"O}zt{\"u}rk`, []Name{
+		{Last: "{World Health Organization}", IsCorporate: true},
+		{First: "John", Last: "Smith"},
+		{Last: `{\"O}zt{\"u}rk`},
+	}, false)
+}
@@ -0,0 +1,111 @@
+package bibtex
+
+import "fmt"
+
+// Parser is a BibTeX parser with configurable options.
+// The zero value is a lenient parser, as used by Parse.
+type Parser struct {
+	// Strict makes the parser reject input that is otherwise accepted with a
+	// warning, e.g. a field defined twice in one entry or a bare word value
+	// that is not a defined string var.
+	Strict bool
+
+	// Conflict decides which entry is kept when files parsed together (e.g.
+	// by ParseDir) have entries with the same cite name.
+	Conflict ConflictPolicy
+
+	// InitialStrings are string vars available to the input without being
+	// defined in it, e.g. from a separate file of abbreviations. Like the
+	// month names, they are overridden by @string definitions in the input
+	// and not written out by RawString.
+	InitialStrings map[string]string
+
+	// CaseSensitiveMacros makes string var names case-sensitive, so that e.g.
+	// ABC and abc are different string vars. This includes the month names,
+	// which are then only defined in lowercase.
+	CaseSensitiveMacros bool
+
+	// NormalizePages rewrites page ranges in pages fields to use --, e.g. 1-10
+	// or 1–10 becomes 1--10.
+	NormalizePages bool
+
+	// NormalizeUnicode converts field values and string var definitions to
+	// Unicode NFC, e.g. an e followed by a combining acute accent becomes é,
+	// so that they compare equal to text typed precomposed. Verbatim fields,
+	// e.g. url or doi, are kept as they are.
+	NormalizeUnicode bool
+
+	// VerbatimFields are the fields whose values are used as they are, e.g.
+	// URLs and file names, compared case-insensitively. They are not decoded
+	// from LaTeX (e.g. by BibEntry.Pairs), normalized by NormalizeUnicode or
+	// changed by BibEntry.FixTypography. If nil, these are url, doi and file.
+	VerbatimFields []string
+
+	// TrackPositions records the line each entry starts on as its Line field.
+	TrackPositions bool
+
+	// KeepRawSource records the source text of each entry as its Raw field.
+	KeepRawSource bool
+
+	// MaxEntries, if positive, limits the number of entries in the input,
+	// including @string, @preamble and @comment entries. Parsing stops with a
+	// LimitExceededError at the first entry over the limit.
+	MaxEntries int
+
+	// MaxValueBytes, if positive, limits the size of a single value, e.g. a
+	// braced field value. Parsing stops with a LimitExceededError as soon as a
+	// value grows over the limit, rather than reading all of it into memory.
+	MaxValueBytes int
+
+	// Recover makes parsing carry on past errors: parsing multiple files
+	// skips files with errors, and a quoted value missing its closing quote
+	// is cut short and ends its entry, with a warning.
+	Recover bool
+
+	// Warnings are the problems found by the last call to Parse that were
+	// not treated as errors.
+	Warnings []error
+
+	// Diagnostics are all the problems found by the last call to Parse that
+	// were not treated as errors, in input order: the Warnings, and input that
+	// is valid but likely a mistake, e.g. a redefined string var, an empty
+	// field value or an author list separated by commas instead of "and".
+	Diagnostics []Diagnostic
+
+	lexer *lexer
+	yacc  bibtexParserImpl
+}
+
+// Severity is how likely a Diagnostic is to be a mistake.
+type Severity int
+
+const (
+	// SeverityInfo is for valid input that may be a mistake.
+	SeverityInfo Severity = iota
+	// SeverityWarning is for input that only a lenient parser accepts, see
+	// Parser.Warnings.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "info"
+}
+
+// Diagnostic is a problem found while parsing that is not an error, see
+// Parser.Diagnostics.
+type Diagnostic struct {
+	File     string // File the problem is in, if parsed from a file.
+	Pos      tokenPos
+	Severity Severity
+	Err      error
+}
+
+func (d Diagnostic) String() string {
+	if d.File != "" {
+		return fmt.Sprintf("%s: %s: %v", d.File, d.Severity, d.Err)
+	}
+	return fmt.Sprintf("%s: %v", d.Severity, d.Err)
+}
@@ -0,0 +1,812 @@
+package bibtex
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+const duplicateField = `@article{x,
+  year = {2019},
+  title = {Title},
+  year = {2020},
+}
+`
+
+// Tests that a field defined twice is an error in strict mode.
+func TestDuplicateFieldStrict(t *testing.T) {
+	p := &Parser{Strict: true}
+	_, err := p.Parse(strings.NewReader(duplicateField))
+	if err == nil {
+		t.Fatal("Expecting duplicate field error")
+	}
+	var perr *ErrParse
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expecting ErrParse but got %T: %v", err, err)
+	}
+	if want, got := "4:3", perr.Pos.String(); want != got {
+		t.Errorf("Expecting error at %s but got %s", want, got)
+	}
+	if !strings.Contains(perr.Err, "year") {
+		t.Errorf("Expecting field name in error but got %q", perr.Err)
+	}
+}
+
+// Tests that the last definition of a duplicate field wins in lenient mode.
+func TestDuplicateFieldLenient(t *testing.T) {
+	p := new(Parser)
+	bib, err := p.Parse(strings.NewReader(duplicateField))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "2020", bib.Entries[0].Fields["year"].String(); want != got {
+		t.Errorf("Expecting year %s but got %s", want, got)
+	}
+	if want, got := 1, len(p.Warnings); want != got {
+		t.Fatalf("Expecting %d warnings but got %d: %v", want, got, p.Warnings)
+	}
+	if !strings.Contains(p.Warnings[0].Error(), "year") {
+		t.Errorf("Expecting field name in warning but got %q", p.Warnings[0])
+	}
+}
+
+// Tests that whitespace in a field name is an error in strict mode and removed
+// in lenient mode.
+func TestFieldNameSpace(t *testing.T) {
+	const input = "@article{a,\n  author  name = {x},\n}"
+	_, err := (&Parser{Strict: true}).Parse(strings.NewReader(input))
+	var perr *ErrParse
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expecting ErrParse but got %T: %v", err, err)
+	}
+	if want, got := "2:3", perr.Pos.String(); want != got {
+		t.Errorf("Expecting error at %s but got %s", want, got)
+	}
+	if !strings.Contains(perr.Err, ErrFieldNameSpace.Error()) {
+		t.Errorf("Expecting field name error but got %q", perr.Err)
+	}
+
+	p := new(Parser)
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "x", bib.Entries[0].Fields["authorname"]; got == nil || want != got.String() {
+		t.Errorf("Expecting authorname %q but got %v (fields %v)", want, got, bib.Entries[0].Fields)
+	}
+	if want, got := 1, len(p.Warnings); want != got {
+		t.Errorf("Expecting %d warning but got %d: %v", want, got, p.Warnings)
+	}
+}
+
+// Tests that a bare word value that is not a string var is an error in strict
+// mode and kept as it is in lenient mode.
+func TestBareValue(t *testing.T) {
+	const input = "@string{greeting = {Hi}}\n@article{a,\n  title = Hello,\n  note = greeting,\n  month = jan,\n}"
+	_, err := (&Parser{Strict: true}).Parse(strings.NewReader(input))
+	var perr *ErrParse
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expecting ErrParse but got %T: %v", err, err)
+	}
+	if want, got := "3:11", perr.Pos.String(); want != got {
+		t.Errorf("Expecting error at %s but got %s", want, got)
+	}
+	if !strings.Contains(perr.Err, "Hello") {
+		t.Errorf("Expecting value in error but got %q", perr.Err)
+	}
+
+	p := new(Parser)
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, expected := range map[string]string{"title": "Hello", "note": "Hi", "month": "January"} {
+		if want, got := expected, bib.Entries[0].Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+	if want, got := "Hello", bib.Entries[0].Fields["title"].RawString(); want != got {
+		t.Errorf("Expecting raw title %q but got %q", want, got)
+	}
+	if want, got := 1, len(p.Warnings); want != got {
+		t.Errorf("Expecting %d warning but got %d: %v", want, got, p.Warnings)
+	}
+
+	if _, err := (&Parser{Strict: true}).Parse(strings.NewReader("@comment{ignored}")); err != nil {
+		t.Errorf("Expecting words in comments to be ignored but got %v", err)
+	}
+}
+
+// Tests that string vars can be supplied to the parser.
+func TestInitialStrings(t *testing.T) {
+	p := &Parser{Strict: true, InitialStrings: map[string]string{"TOSEM": "ACM Trans. Softw. Eng. Methodol.", "jan": "Jan."}}
+	bib, err := p.Parse(strings.NewReader(`@article{a, journal = tosem # { 31}, month = jan}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, expected := range map[string]string{"journal": "ACM Trans. Softw. Eng. Methodol. 31", "month": "Jan."} {
+		if want, got := expected, bib.Entries[0].Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+	if s := bib.RawString(); strings.Contains(s, "@string") {
+		t.Errorf("Expecting initial strings not to be written out:\n%s", s)
+	}
+}
+
+func TestCaseSensitiveMacros(t *testing.T) {
+	input := `@string{ABC = "upper"}
+@string{abc = "lower"}
+@article{a, journal = ABC, publisher = abc, month = jan}`
+	for _, test := range []struct {
+		p                  *Parser
+		journal, publisher string
+	}{
+		{&Parser{Strict: true}, "lower", "lower"},
+		{&Parser{Strict: true, CaseSensitiveMacros: true}, "upper", "lower"},
+	} {
+		bib, err := test.p.Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for field, expected := range map[string]string{"journal": test.journal, "publisher": test.publisher, "month": "January"} {
+			if want, got := expected, bib.Entries[0].Fields[field].String(); want != got {
+				t.Errorf("CaseSensitiveMacros %t: expecting %s %q but got %q", test.p.CaseSensitiveMacros, field, want, got)
+			}
+		}
+	}
+
+	p := &Parser{CaseSensitiveMacros: true}
+	bib, err := p.Parse(strings.NewReader(`@article{a, month = Jan}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(p.Warnings); want != got {
+		t.Errorf("Expecting %d warning for Jan but got %d", want, got)
+	}
+	if want, got := "Jan", bib.Entries[0].Fields["month"].String(); want != got {
+		t.Errorf("Expecting month %q but got %q", want, got)
+	}
+}
+
+// Tests that vertical tabs, form feeds and no-break spaces separate tokens,
+// and are kept in values.
+func TestUnusualWhitespace(t *testing.T) {
+	bib, err := Parse(strings.NewReader("@article{a,\f\ttitle = {A\u00a0B},\v\u00a0note\u00a0=\f\"\fC\u00a0D \",\nyear = 2020\u00a0\f}\f@misc{b,}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for field, expected := range map[string]string{"title": "A\u00a0B", "note": "\fC\u00a0D ", "year": "2020"} {
+		if want, got := expected, bib.Entries[0].Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+}
+
+// Tests that multibyte runes split across reads are decoded correctly.
+func TestOneByteReader(t *testing.T) {
+	const input = "@article{mueller2020,\n  author = {Müller, Jürgen and Čapek, Karel},\n  title = \"Über Straßen — 東京\",\n}\n"
+	bib, err := Parse(iotest.OneByteReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEntryListsEqual(t, expected.Entries, bib.Entries)
+	if want, got := "Über Straßen — 東京", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := "Müller, Jürgen and Čapek, Karel", bib.Entries[0].Fields["author"].String(); want != got {
+		t.Errorf("Expecting author %q but got %q", want, got)
+	}
+}
+
+// Tests that page ranges are normalized on import if enabled.
+func TestNormalizePages(t *testing.T) {
+	for input, expected := range map[string]string{
+		"1-10":           "1--10",
+		"1--10":          "1--10",
+		"1---10":         "1--10",
+		"1–10":           "1--10",
+		"1 — 10":         "1--10",
+		"S12-S15, 20–22": "S12--S15, 20--22",
+		"e1234":          "e1234",
+		"123":            "123",
+	} {
+		bib, err := (&Parser{NormalizePages: true}).Parse(strings.NewReader("@article{a, pages = {" + input + "}, note = {1-2}}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := expected, bib.Entries[0].Fields["pages"].String(); want != got {
+			t.Errorf("Expecting pages %q to be %q but got %q", input, want, got)
+		}
+		if want, got := "1-2", bib.Entries[0].Fields["note"].String(); want != got {
+			t.Errorf("Expecting note %q but got %q", want, got)
+		}
+	}
+	bib, err := Parse(strings.NewReader("@article{a, pages = {1-10}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "1-10", bib.Entries[0].Fields["pages"].String(); want != got {
+		t.Errorf("Expecting pages %q by default but got %q", want, got)
+	}
+}
+
+// Tests that empty fields (e.g. trailing commas) are not duplicates.
+func TestEmptyFields(t *testing.T) {
+	p := &Parser{Strict: true}
+	for _, input := range []string{
+		`@article{x,}`,
+		`@article{x, year = {2019},}`,
+		`@article{x,, year = {2019},, title = {Title},}`,
+	} {
+		if _, err := p.Parse(strings.NewReader(input)); err != nil {
+			t.Errorf("Cannot parse %q: %v", input, err)
+		}
+	}
+}
+
+// Tests that a field in place of the cite key is a clear error.
+func TestUnexpectedEqualInKey(t *testing.T) {
+	for input, pos := range map[string]string{
+		"@article{key = x}":                      "1:14",
+		"@article(key = x)":                      "1:14",
+		"@article{\n  title = {T},\n  year = 1}": "2:9",
+	} {
+		_, err := Parse(strings.NewReader(input))
+		var perr *ErrParse
+		if !errors.As(err, &perr) {
+			t.Errorf("Expecting ErrParse for %q but got %v", input, err)
+			continue
+		}
+		if want, got := ErrUnexpectedEqual.Error(), perr.Err; want != got {
+			t.Errorf("Expecting error %q for %q but got %q", want, input, got)
+		}
+		if want, got := pos, perr.Pos.String(); want != got {
+			t.Errorf("Expecting error at %s for %q but got %s", want, input, got)
+		}
+	}
+}
+
+// Tests that a @string without a definition is a clear error.
+func TestEmptyStringEntry(t *testing.T) {
+	for input, pos := range map[string]string{
+		"@string{}":                           "1:9",
+		"@string()":                           "1:9",
+		"@string{=}":                          "1:9",
+		"@string( = {x})":                     "1:10",
+		"@article{a, title = {T}}\n@string{}": "2:9",
+	} {
+		_, err := Parse(strings.NewReader(input))
+		var perr *ErrParse
+		if !errors.As(err, &perr) {
+			t.Errorf("Expecting ErrParse for %q but got %v", input, err)
+			continue
+		}
+		if want, got := ErrEmptyStringEntry.Error(), perr.Err; want != got {
+			t.Errorf("Expecting error %q for %q but got %q", want, input, got)
+		}
+		if want, got := pos, perr.Pos.String(); want != got {
+			t.Errorf("Expecting error at %s for %q but got %s", want, input, got)
+		}
+	}
+}
+
+// Tests that a @string in parentheses must be closed by a parenthesis.
+func TestStringEntryParens(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string(x = {y}) @article{a, title = x}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "y", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if _, err := Parse(strings.NewReader(`@string(x = {y}}`)); err == nil {
+		t.Error("Expecting @string( closed by } to be an error")
+	}
+}
+
+// Tests that a Parser can be reused without state from earlier parses.
+func TestParserReuse(t *testing.T) {
+	p := &Parser{Strict: true}
+	for i := 0; i < 3; i++ {
+		bib, err := p.Parse(strings.NewReader(`@string{x = {X}}
+@article{a, title = x, year = 2020}
+@article{b, title = {B}}
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := 2, len(bib.Entries); want != got {
+			t.Errorf("Expecting %d entries but got %d", want, got)
+		}
+		if want, got := "X", bib.Entries[0].Fields["title"].String(); want != got {
+			t.Errorf("Expecting title %q but got %q", want, got)
+		}
+
+		// A failed parse should not affect the next one.
+		if _, err := p.Parse(strings.NewReader(duplicateField)); err == nil {
+			t.Error("Expecting duplicate field error")
+		}
+
+		// Neither should one stopped inside a field value.
+		if _, err := p.Parse(strings.NewReader(`@article{a, title = `)); err == nil {
+			t.Error("Expecting error for truncated input")
+		}
+		bib, err = p.Parse(strings.NewReader(`@article{c, year = 2021}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := 1, len(bib.Entries); want != got {
+			t.Errorf("Expecting %d entries but got %d", want, got)
+		}
+		if _, ok := bib.StringVar["x"]; ok {
+			t.Error("String var x should not be defined in a new parse")
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	exampleFileBytes, err := ioutil.ReadFile("example/biblatex-examples.bib")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(bytes.NewReader(exampleFileBytes)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// smallInput is a single entry, as a server parsing many small requests sees.
+// The setup of a parse, e.g. its read buffer, dominates such parses, which is
+// what a pooled Parser saves on.
+const smallInput = `@article{a, author = {Doe, Jane}, title = {A Title}, year = 2020}`
+
+func BenchmarkParseSmall(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(strings.NewReader(smallInput)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParserPool(b *testing.B) {
+	pool := sync.Pool{New: func() interface{} { return new(Parser) }}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := pool.Get().(*Parser)
+		if _, err := p.Parse(strings.NewReader(smallInput)); err != nil {
+			b.Fatal(err)
+		}
+		p.Reset(nil)
+		pool.Put(p)
+	}
+}
+
+// Tests that a quoted value closed by a brace is reported where it opens, and
+// that the parser can resync at the next entry.
+func TestUnterminatedQuote(t *testing.T) {
+	input := "@article{a,\n  title = \"unbalanced},\n  year = 2020\n}\n@article{b, title = \"B\"}\n"
+	_, err := Parse(strings.NewReader(input))
+	var perr *ErrParse
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expecting ErrParse but got %T: %v", err, err)
+	}
+	if want, got := ErrUnterminatedString.Error(), perr.Err; want != got {
+		t.Errorf("Expecting error %q but got %q", want, got)
+	}
+	if want, got := "2:11", perr.Pos.String(); want != got {
+		t.Errorf("Expecting error at %s but got %s", want, got)
+	}
+
+	p := &Parser{Recover: true}
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(p.Warnings); want != got {
+		t.Fatalf("Expecting %d warning but got %d: %v", want, got, p.Warnings)
+	}
+	if !errors.As(p.Warnings[0], &perr) || perr.Pos.String() != "2:11" {
+		t.Errorf("Expecting warning at 2:11 but got %v", p.Warnings[0])
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	if want, got := "unbalanced", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting repaired title %q but got %q", want, got)
+	}
+	if want, got := "B", bib.Entries[1].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+
+	bib, err = p.Parse(strings.NewReader("@article(c, title = \"To EOF\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "To EOF", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting repaired title %q but got %q", want, got)
+	}
+}
+
+// Tests that the source text of each entry is kept exactly.
+func TestKeepRawSource(t *testing.T) {
+	raws := []string{
+		"@article{a,\n  title = {A {Nested} Title},\n  author = \"Gödel, Kurt\",\n}",
+		"@Book( b , title = x # \"y\" )",
+		"@misc{c,}",
+	}
+	input := "@string{x = {X}}\n" + raws[0] + "\n\n@comment{ignored}\n" + raws[1] + "  " + raws[2] + "\n"
+	p := &Parser{KeepRawSource: true}
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := len(raws), len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, entry := range bib.Entries {
+		if want, got := raws[i], entry.Raw; want != got {
+			t.Errorf("Expecting raw source %q but got %q", want, got)
+		}
+	}
+
+	bib, err = Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bib.Entries[0].Raw; got != "" {
+		t.Errorf("Expecting no raw source by default but got %q", got)
+	}
+}
+
+// Tests that the line each entry starts on is recorded.
+func TestTrackPositions(t *testing.T) {
+	input := `@string{x = {X}}
+@article{a, title = {A}}
+
+@comment{
+  spanning lines
+}
+@book{b,
+  title = {B
+    on two lines},
+} @misc(c, note = x)
+  @online{d,}`
+	p := &Parser{TrackPositions: true}
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{2, 7, 10, 11}
+	if want, got := len(expected), len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, entry := range bib.Entries {
+		if want, got := expected[i], entry.Line; want != got {
+			t.Errorf("Expecting %s to start on line %d but got %d", entry.CiteName, want, got)
+		}
+	}
+
+	bib, err = Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bib.Entries[1].Line; got != 0 {
+		t.Errorf("Expecting no line by default but got %d", got)
+	}
+}
+
+// Tests that whitespace between @ and the entry type is skipped.
+func TestSpaceAfterAtSign(t *testing.T) {
+	bib, err := Parse(strings.NewReader("@ string{x = {X}}\n@ article{key, title = x}\n@\n\tmisc (other, note = {N})\n@ comment{c}\n@  preamble{\"p\"}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, expected := range [][2]string{{"article", "key"}, {"misc", "other"}} {
+		if want, got := expected, [2]string{bib.Entries[i].Type, bib.Entries[i].CiteName}; want != got {
+			t.Errorf("Expecting entry %v but got %v", want, got)
+		}
+	}
+	if want, got := "X", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := 1, len(bib.Preambles); want != got {
+		t.Errorf("Expecting %d preamble but got %d", want, got)
+	}
+}
+
+// Tests that whitespace, including line breaks, is allowed between the entry
+// type and the opening brace or parenthesis.
+func TestSpaceBeforeBrace(t *testing.T) {
+	bib, err := Parse(strings.NewReader("@string \n {x = {X}}\n@article\n  {key, title = x}\n@misc \t\n(other, note = {N})\n@preamble\n{\"p\"}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, expected := range [][2]string{{"article", "key"}, {"misc", "other"}} {
+		if want, got := expected, [2]string{bib.Entries[i].Type, bib.Entries[i].CiteName}; want != got {
+			t.Errorf("Expecting entry %v but got %v", want, got)
+		}
+	}
+	if want, got := "X", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := 1, len(bib.Preambles); want != got {
+		t.Errorf("Expecting %d preamble but got %d", want, got)
+	}
+}
+
+// Tests that parsing stops at the first entry over Parser.MaxEntries.
+func TestMaxEntries(t *testing.T) {
+	input := "@string{x = {X}}\n@article{a, title = x}\n@misc{b, note = {N}}\n"
+	p := &Parser{MaxEntries: 2}
+	_, err := p.Parse(strings.NewReader(input))
+	var limitErr *LimitExceededError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expecting a LimitExceededError but got %v", err)
+	}
+	if want, got := "MaxEntries", limitErr.Limit; want != got {
+		t.Errorf("Expecting limit %s but got %s", want, got)
+	}
+	if want, got := "3:1", limitErr.Pos.String(); want != got {
+		t.Errorf("Expecting error at %s but got %s", want, got)
+	}
+
+	p.MaxEntries = 3
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Errorf("Expecting %d entries but got %d", want, got)
+	}
+}
+
+// Tests that parsing stops at a value over Parser.MaxValueBytes without
+// reading the rest of it.
+func TestMaxValueBytes(t *testing.T) {
+	huge := strings.Repeat("x", 1<<20)
+	for _, input := range []string{
+		"@misc{a, note = {" + huge + "}}",
+		"@misc{a, note = \"" + huge + "\"}",
+		"@misc{a, note = " + huge + "}",
+		"@preamble{\"" + huge + "\"}",
+	} {
+		r := strings.NewReader(input)
+		p := &Parser{MaxValueBytes: 1024}
+		_, err := p.Parse(r)
+		var limitErr *LimitExceededError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("%.20s: expecting a LimitExceededError but got %v", input, err)
+			continue
+		}
+		if want, got := "MaxValueBytes", limitErr.Limit; want != got {
+			t.Errorf("%.20s: expecting limit %s but got %s", input, want, got)
+		}
+		if r.Len() < len(huge)/2 {
+			t.Errorf("%.20s: expecting parsing to stop early but %d bytes were read", input, len(input)-r.Len())
+		}
+	}
+
+	p := &Parser{MaxValueBytes: 5}
+	bib, err := p.Parse(strings.NewReader(`@misc{a, note = {12345}, title = "abcde"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "12345", bib.Entries[0].Fields["note"].String(); want != got {
+		t.Errorf("Expecting note %q but got %q", want, got)
+	}
+}
+
+// Tests that values are converted to NFC, except verbatim fields.
+func TestNormalizeUnicode(t *testing.T) {
+	const nfd = "Cafe\u0301"
+	input := "@string{place = \"Zu\u0308rich\"}\n@misc{a, title = {" + nfd + "}, address = place # \", Gene\u0300ve\", url = {http://example.com/" + nfd + "}}"
+	p := &Parser{NormalizeUnicode: true}
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	for field, expected := range map[string]string{
+		"title":   "Caf\u00e9",
+		"address": "Z\u00fcrich, Gen\u00e8ve",
+		"url":     "http://example.com/" + nfd,
+	} {
+		if want, got := expected, entry.Fields[field].String(); want != got {
+			t.Errorf("Expecting %s %q but got %q", field, want, got)
+		}
+	}
+
+	bib, err = Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := nfd, bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title to be kept as %q by default but got %q", want, got)
+	}
+}
+
+// Tests that a quote protected by braces does not end a quoted value.
+func TestProtectedQuote(t *testing.T) {
+	for value, expected := range map[string]string{
+		`"{"}"`:               `{"}`,
+		`"a {"} b"`:           `a {"} b`,
+		`"a {b"c} d"`:         `a {b"c} d`,
+		`"{"}Hello{"} world"`: `{"}Hello{"} world`,
+		`"x {{"}} y"`:         `x {{"}} y`,
+	} {
+		bib, err := Parse(strings.NewReader(`@misc{a, title = ` + value + `, note = {after}}`))
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+			continue
+		}
+		entry := bib.Entries[0]
+		if want, got := expected, entry.Fields["title"].String(); want != got {
+			t.Errorf("%s: expecting title %q but got %q", value, want, got)
+		}
+		if want, got := "after", entry.Fields["note"].String(); want != got {
+			t.Errorf("%s: expecting note %q after the title but got %q", value, want, got)
+		}
+	}
+}
+
+// Tests that warnings and likely mistakes are collected as diagnostics.
+func TestDiagnostics(t *testing.T) {
+	input := `@string{pub = {ACM}}
+@string{PUB = {ACM Press}}
+@article{a,
+  author = {Smith, John, Doe, Jane},
+  title = {},
+  journal = unknown,
+  year = 2020,
+  year = 2021,
+}`
+	p := new(Parser)
+	if _, err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		pos      string
+		severity Severity
+		err      error
+	}{
+		{"2:9", SeverityInfo, ErrRedefinedStringVar},
+		{"4:3", SeverityInfo, ErrSuspiciousAuthors},
+		{"5:3", SeverityInfo, ErrEmptyField},
+		{"6:13", SeverityWarning, ErrUnknownStringVar},
+		{"8:3", SeverityWarning, ErrDuplicateField},
+	}
+	if want, got := len(expected), len(p.Diagnostics); want != got {
+		t.Fatalf("Expecting %d diagnostics but got %d: %v", want, got, p.Diagnostics)
+	}
+	for i, d := range p.Diagnostics {
+		if want, got := expected[i].pos, d.Pos.String(); want != got {
+			t.Errorf("Expecting diagnostic %d at %s but got %s: %v", i, want, got, d)
+		}
+		if want, got := expected[i].severity, d.Severity; want != got {
+			t.Errorf("Expecting diagnostic %d to be %s but got %s: %v", i, want, got, d)
+		}
+		if !strings.Contains(d.Err.Error(), expected[i].err.Error()) {
+			t.Errorf("Expecting diagnostic %d to be %v but got %v", i, expected[i].err, d)
+		}
+	}
+	if want, got := 2, len(p.Warnings); want != got {
+		t.Errorf("Expecting %d warnings but got %d: %v", want, got, p.Warnings)
+	}
+
+	if _, err := p.Parse(strings.NewReader(`@misc{b, title = {T}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Diagnostics) != 0 {
+		t.Errorf("Expecting diagnostics to be reset but got %v", p.Diagnostics)
+	}
+}
+
+// Tests that an entry may start right after the closing brace of the last.
+func TestBackToBackEntries(t *testing.T) {
+	input := `@string{s={S}}@article{a,title=s}@comment{c}@preamble{"p"}@book(b,title="y")@misc{c,note=1}`
+	p := &Parser{KeepRawSource: true}
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`@article{a,title=s}`, `@book(b,title="y")`, `@misc{c,note=1}`}
+	if want, got := len(expected), len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, entry := range bib.Entries {
+		if want, got := expected[i], entry.Raw; want != got {
+			t.Errorf("Expecting entry %d to be %q but got %q", i, want, got)
+		}
+	}
+	if want, got := "S", bib.Entries[0].Fields["title"].String(); want != got {
+		t.Errorf("Expecting title %q but got %q", want, got)
+	}
+	if want, got := 1, len(bib.Preambles); want != got {
+		t.Errorf("Expecting %d preamble but got %d", want, got)
+	}
+}
+
+// Tests that text outside entries is skipped, as by BibTeX, rather than
+// ending the input.
+func TestTextOutsideEntries(t *testing.T) {
+	input := `% Header comment.
+@article{a, title = {A}}  % fixed 2021
+% comment between entries
+Some prose, with {braces} and "quotes".
+@book{b, title = {B}}
+} trailing text
+`
+	p := &Parser{KeepRawSource: true, TrackPositions: true}
+	bib, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, expected := range []struct {
+		raw  string
+		line int
+	}{{`@article{a, title = {A}}`, 2}, {`@book{b, title = {B}}`, 5}} {
+		entry := bib.Entries[i]
+		if want, got := expected.raw, entry.Raw; want != got {
+			t.Errorf("Expecting entry %d to be %q but got %q", i, want, got)
+		}
+		if want, got := expected.line, entry.Line; want != got {
+			t.Errorf("Expecting entry %d on line %d but got %d", i, want, got)
+		}
+	}
+}
+
+// Tests that only the configured verbatim fields are kept as they are.
+func TestVerbatimFields(t *testing.T) {
+	const input = `@misc{a, title = {a--b}, url = {http://x/a--b}, Note = {a--b ’}}`
+	bib, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Pair{{"title", "a–b"}, {"url", "http://x/a--b"}, {"Note", "a–b ’"}}
+	if got := bib.Entries[0].Pairs(true); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting default verbatim pairs %v but got %v", expected, got)
+	}
+
+	p := &Parser{VerbatimFields: []string{"note"}}
+	bib, err = p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	expected = []Pair{{"title", "a–b"}, {"url", "http://x/a–b"}, {"Note", "a--b ’"}}
+	if got := entry.Pairs(true); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting verbatim pairs %v but got %v", expected, got)
+	}
+	entry.FixTypography()
+	if want, got := "a--b ’", entry.Fields["Note"].String(); want != got {
+		t.Errorf("Expecting verbatim note %q to be kept but got %q", want, got)
+	}
+}
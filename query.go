@@ -0,0 +1,289 @@
+package bibtex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query returns the entries of bib matching the expression expr, in order.
+//
+// An expression combines conditions on the fields of an entry with && (and),
+// || (or), ! (not) and parentheses, e.g.
+//
+//	type=article && year>=2020 && has(doi)
+//
+// The conditions are:
+//
+//	field = value, field != value   equal, case-insensitively
+//	field < number, <=, >, >=       numeric comparison
+//	has(field)                      field is set and not empty
+//	contains(field, "text")         field contains text, case-insensitively
+//
+// The field type is the entry type and key its cite name, other names are
+// fields, compared with LaTeX decoded. Values are numbers, quoted strings or
+// bare words. A numeric comparison uses the leading number of the field, see
+// BibEntry.Year for year, and is false if it has none.
+func (bib *BibTex) Query(expr string) ([]*BibEntry, error) {
+	p := &queryParser{s: expr}
+	p.next()
+	match, err := p.parseOr()
+	if err == nil && p.tok.kind != qEOF {
+		err = p.errorf("unexpected %q", p.tok.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*BibEntry
+	for _, entry := range bib.Entries {
+		if match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// queryFunc is a compiled query condition.
+type queryFunc func(entry *BibEntry) bool
+
+type queryKind int
+
+const (
+	qEOF queryKind = iota
+	qIdent
+	qNumber
+	qString
+	qOp // Operators and punctuation.
+)
+
+type queryToken struct {
+	kind queryKind
+	text string
+	pos  int
+}
+
+// queryParser is a recursive descent parser of query expressions.
+type queryParser struct {
+	s   string
+	i   int // Position in s.
+	tok queryToken
+	err error // Error from scanning tok.
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at %d: %s", ErrBadQuery, p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+// next scans the next token into p.tok.
+func (p *queryParser) next() {
+	for p.i < len(p.s) && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+	start := p.i
+	p.tok = queryToken{pos: start}
+	if p.i == len(p.s) {
+		return
+	}
+	switch ch := p.s[p.i]; {
+	case ch == '"':
+		var b strings.Builder
+		for p.i++; p.i < len(p.s) && p.s[p.i] != '"'; p.i++ {
+			if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+				p.i++
+			}
+			b.WriteByte(p.s[p.i])
+		}
+		if p.i < len(p.s) {
+			p.i++ // Closing quote.
+		} else {
+			p.err = fmt.Errorf("%w at %d: unterminated string", ErrBadQuery, start)
+		}
+		p.tok.kind, p.tok.text = qString, b.String()
+		return
+	case isDigit(rune(ch)) || ch == '-':
+		for p.i++; p.i < len(p.s) && isDigit(rune(p.s[p.i])); p.i++ {
+		}
+		p.tok.kind = qNumber
+	case isAlphanum(rune(ch)) || ch == '_':
+		for p.i++; p.i < len(p.s) && (isAlphanum(rune(p.s[p.i])) || isBareSymbol(rune(p.s[p.i]))); p.i++ {
+		}
+		p.tok.kind = qIdent
+	default:
+		p.tok.kind = qOp
+		p.i++
+		for _, op := range []string{"&&", "||", "==", "!=", "<=", ">="} {
+			if strings.HasPrefix(p.s[start:], op) {
+				p.i = start + len(op)
+			}
+		}
+	}
+	p.tok.text = p.s[start:p.i]
+}
+
+// accept consumes the current token if it is the operator op.
+func (p *queryParser) accept(op string) bool {
+	if p.tok.kind == qOp && p.tok.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(op string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if !p.accept(op) {
+		return p.errorf("expecting %q", op)
+	}
+	return nil
+}
+
+func (p *queryParser) parseOr() (queryFunc, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right queryFunc
+		if right, err = p.parseAnd(); err == nil {
+			l := left
+			left = func(entry *BibEntry) bool { return l(entry) || right(entry) }
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseAnd() (queryFunc, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right queryFunc
+		if right, err = p.parseUnary(); err == nil {
+			l := left
+			left = func(entry *BibEntry) bool { return l(entry) && right(entry) }
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseUnary() (queryFunc, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.accept("!") {
+		cond, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(entry *BibEntry) bool { return !cond(entry) }, nil
+	}
+	if p.accept("(") {
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(")")
+	}
+	if p.tok.kind != qIdent {
+		return nil, p.errorf("expecting field name")
+	}
+	name := p.tok.text
+	p.next()
+	if p.accept("(") {
+		return p.parseCall(name)
+	}
+	return p.parseComparison(name)
+}
+
+// parseCall parses the arguments of the function name, after its (.
+func (p *queryParser) parseCall(name string) (queryFunc, error) {
+	if p.tok.kind != qIdent {
+		return nil, p.errorf("expecting field name")
+	}
+	field := p.tok.text
+	p.next()
+	switch strings.ToLower(name) {
+	case "has":
+		return func(entry *BibEntry) bool { return queryField(entry, field) != "" }, p.expect(")")
+	case "contains":
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == qOp || p.tok.kind == qEOF {
+			return nil, p.errorf("expecting value")
+		}
+		text := strings.ToLower(p.tok.text)
+		p.next()
+		return func(entry *BibEntry) bool {
+			return strings.Contains(strings.ToLower(queryField(entry, field)), text)
+		}, p.expect(")")
+	}
+	return nil, fmt.Errorf("%w: unknown function %s", ErrBadQuery, name)
+}
+
+// parseComparison parses a comparison of field, after its name.
+func (p *queryParser) parseComparison(field string) (queryFunc, error) {
+	op := p.tok.text
+	if p.tok.kind != qOp || !strings.Contains(" = == != < <= > >= ", " "+op+" ") {
+		return nil, p.errorf("expecting comparison after %s", field)
+	}
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == qOp || p.tok.kind == qEOF {
+		return nil, p.errorf("expecting value")
+	}
+	value := p.tok.text
+	kind := p.tok.kind
+	p.next()
+
+	switch op {
+	case "=", "==":
+		return func(entry *BibEntry) bool { return strings.EqualFold(queryField(entry, field), value) }, nil
+	case "!=":
+		return func(entry *BibEntry) bool { return !strings.EqualFold(queryField(entry, field), value) }, nil
+	}
+	if kind != qNumber {
+		return nil, fmt.Errorf("%w: %s %s %s is not a numeric comparison", ErrBadQuery, field, op, value)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not a number", ErrBadQuery, value)
+	}
+	cmp := map[string]func(a, b int) bool{
+		"<":  func(a, b int) bool { return a < b },
+		"<=": func(a, b int) bool { return a <= b },
+		">":  func(a, b int) bool { return a > b },
+		">=": func(a, b int) bool { return a >= b },
+	}[op]
+	return func(entry *BibEntry) bool {
+		v, ok := queryNumber(entry, field)
+		return ok && cmp(v, n)
+	}, nil
+}
+
+// queryField returns the value of the named field of entry for a query.
+func queryField(entry *BibEntry, name string) string {
+	switch strings.ToLower(name) {
+	case "type":
+		return entry.Type
+	case "key":
+		return entry.CiteName
+	}
+	if val, ok := entry.field(name); ok {
+		return strings.TrimSpace(entry.decodeField(name, val.String()))
+	}
+	return ""
+}
+
+// queryNumber returns the leading number of the named field of entry.
+func queryNumber(entry *BibEntry, name string) (int, bool) {
+	if strings.EqualFold(name, "year") {
+		return entry.Year()
+	}
+	m := leadingYear.FindStringSubmatch(queryField(entry, name))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
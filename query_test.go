@@ -0,0 +1,63 @@
+package bibtex
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, title = {Parsing {BibTeX} in Go}, year = 2021, doi = {10.1/a}, volume = {12}}
+@Article{b, title = {G{\"o}del and Gophers}, year = {2019}, volume = 3}
+@book{c, title = {A Book}, date = {2020-05}, doi = {}}
+@inproceedings{d, title = {Go Tools}, year = 2022, doi = {10.1/d}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for expr, expected := range map[string][]string{
+		`type=article && year>=2020 && has(doi)`:    {"a"},
+		`type == ARTICLE`:                           {"a", "b"},
+		`year < 2021`:                               {"b", "c"},
+		`has(doi)`:                                  {"a", "d"},
+		`!has(doi) || volume > 10`:                  {"a", "b", "c"},
+		`contains(title, "go") && !(type=book)`:     {"a", "b", "d"},
+		`contains(title, "gödel")`:                  {"b"},
+		`key != a && (year <= 2019 || year = 2022)`: {"b", "d"},
+		`title = "a book"`:                          {"c"},
+	} {
+		entries, err := bib.Query(expr)
+		if err != nil {
+			t.Errorf("Cannot run query %q: %v", expr, err)
+			continue
+		}
+		var got []string
+		for _, entry := range entries {
+			got = append(got, entry.CiteName)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expecting %q to match %v but got %v", expr, expected, got)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	bib := NewBibTex()
+	for _, expr := range []string{
+		``,
+		`year >=`,
+		`year > abc`,
+		`has(doi`,
+		`type = article &&`,
+		`unknown(x)`,
+		`contains(title, "x)`,
+		`(year = 2020`,
+		`year = 2020 2021`,
+		`type`,
+	} {
+		if _, err := bib.Query(expr); !errors.Is(err, ErrBadQuery) {
+			t.Errorf("Expecting query error for %q but got %v", expr, err)
+		}
+	}
+}
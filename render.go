@@ -0,0 +1,102 @@
+package bibtex
+
+import "strings"
+
+// Style is a plain text citation style, see RenderText.
+type Style int
+
+const (
+	// StyleAuthorYear is an APA-like style, e.g.
+	// "Smith, J., & Doe, J. (2020). Title. Journal."
+	StyleAuthorYear Style = iota
+	// StyleAuthorTitle is an MLA-like style, e.g.
+	// "Smith, John and Jane Doe. Title. Journal, 2020."
+	StyleAuthorTitle
+)
+
+// RenderText renders bib as plain text, one "key: citation" line per entry,
+// with LaTeX special characters decoded. Missing fields are left out.
+func (bib *BibTex) RenderText(style Style) string {
+	var buf strings.Builder
+	for _, entry := range bib.Entries {
+		buf.WriteString(entry.CiteName)
+		buf.WriteString(":")
+		for _, part := range entry.citation(style) {
+			buf.WriteString(" ")
+			buf.WriteString(part)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// citation returns the sentences of the citation of entry in style.
+func (entry *BibEntry) citation(style Style) []string {
+	names := renderNames(entry.firstField("author", "editor"), style)
+	title := entry.DisplayTitle()
+	venue := decodeLaTeX(entry.firstField("journal", "booktitle", "publisher", "school", "institution"), true)
+	year := entry.firstField("year")
+	if year == "" {
+		if date := entry.firstField("date"); len(date) >= 4 {
+			year = date[:4]
+		}
+	}
+
+	var parts []string
+	add := func(s string) {
+		if s != "" {
+			if !strings.HasSuffix(s, ".") && !strings.HasSuffix(s, "?") && !strings.HasSuffix(s, "!") {
+				s += "."
+			}
+			parts = append(parts, s)
+		}
+	}
+	switch style {
+	case StyleAuthorTitle:
+		add(names)
+		add(title)
+		if year != "" && venue != "" {
+			venue += ", "
+		}
+		add(venue + year)
+	default:
+		if year != "" {
+			names = strings.TrimSpace(names + " (" + year + ")")
+		}
+		add(names)
+		add(title)
+		add(venue)
+	}
+	return parts
+}
+
+// renderNames formats a list of names for the given style.
+func renderNames(s string, style Style) string {
+	list := ParseNames(s)
+	var names []string
+	for i, name := range list.Names {
+		switch {
+		case style == StyleAuthorYear:
+			names = append(names, name.Format(NameLastInitials))
+		case i == 0:
+			names = append(names, name.String())
+		default:
+			names = append(names, name.Format(NameFirstLast))
+		}
+	}
+
+	var out string
+	switch {
+	case len(names) == 0:
+		return ""
+	case list.HasOthers:
+		out = strings.Join(names, ", ") + " et al."
+	case len(names) == 1:
+		out = names[0]
+	case style == StyleAuthorYear:
+		out = strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+	default:
+		out = strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	}
+	return decodeLaTeX(out, true)
+}
@@ -0,0 +1,35 @@
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderText(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{smith2020,
+  author = {Smith, John and Jane Doe},
+  title = {A {Study} of G{\"o}del},
+  journal = {Journal},
+  year = 2020,
+}
+@book{knuth, author = {Donald E. Knuth and others}, title = {The Art of Computer Programming}}
+@misc{anon, title = {Why?}, date = {2019-03-15}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for style, expected := range map[Style]string{
+		StyleAuthorYear: `smith2020: Smith, J., & Doe, J. (2020). A Study of Gödel. Journal.
+knuth: Knuth, D. E. et al. The Art of Computer Programming.
+anon: (2019). Why?
+`,
+		StyleAuthorTitle: `smith2020: Smith, John and Jane Doe. A Study of Gödel. Journal, 2020.
+knuth: Knuth, Donald E. et al. The Art of Computer Programming.
+anon: Why? 2019.
+`,
+	} {
+		if s := bib.RenderText(style); s != expected {
+			t.Errorf("Style %d does not match, got:\n%s\nexpected:\n%s", style, s, expected)
+		}
+	}
+}
@@ -4,16 +4,30 @@ import (
 	"bufio"
 	"bytes"
 	"io"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-var parseField bool
-
 // scanner is a lexical scanner
 type scanner struct {
-	r   *bufio.Reader
-	pos tokenPos
+	r     *bufio.Reader
+	buf   bytes.Buffer // Reused for scanning strings.
+	pos   tokenPos
+	start tokenPos      // Start of the last scanned token.
+	delim DelimiterKind // Delimiter of the last scanned string.
+
+	// unterminated is set if the last scanned quoted string was not closed
+	// before the next entry or EOF.
+	unterminated bool
+
+	maxValue int  // Maximum size of a value in bytes, if positive.
+	tooLong  bool // Whether the last scanned value was over maxValue.
+
+	parseField bool // Whether the scanner is in a field value.
+
+	record   bool         // Whether to record the source text read.
+	recorded bytes.Buffer // Source text read since recording started.
+	lastSize int          // Size of the last rune recorded, for unread.
 }
 
 // newScanner returns a new instance of scanner.
@@ -21,13 +35,39 @@ func newScanner(r io.Reader) *scanner {
 	return &scanner{r: bufio.NewReader(r), pos: tokenPos{Char: 0, Lines: []int{}}}
 }
 
+// reset discards the scanner state and makes it read from r.
+func (s *scanner) reset(r io.Reader) {
+	s.r.Reset(r)
+	s.buf.Reset()
+	s.pos = tokenPos{Char: 0, Lines: s.pos.Lines[:0]}
+	s.start = tokenPos{}
+	s.delim = DelimBraces
+	s.unterminated = false
+	s.tooLong = false
+	s.parseField = false
+	s.record = false
+	s.recorded.Reset()
+}
+
+// startRecording records the source text read from now on, starting with
+// prefix (i.e. text already read).
+func (s *scanner) startRecording(prefix string) {
+	s.record = true
+	s.recorded.Reset()
+	s.recorded.WriteString(prefix)
+}
+
 // read reads the next rune from the buffered reader.
 // Returns the rune(0) if an error occurs (or io.eof is returned).
 func (s *scanner) read() rune {
 	ch, _, err := s.r.ReadRune()
 	if err != nil {
+		s.lastSize = 0
 		return eof
 	}
+	if s.record {
+		s.lastSize, _ = s.recorded.WriteRune(ch)
+	}
 	if ch == '\n' {
 		s.pos.Lines = append(s.pos.Lines, s.pos.Char)
 		s.pos.Char = 0
@@ -40,6 +80,9 @@ func (s *scanner) read() rune {
 // unread places the previously read rune back on the reader.
 func (s *scanner) unread() {
 	_ = s.r.UnreadRune()
+	if s.record {
+		s.recorded.Truncate(s.recorded.Len() - s.lastSize)
+	}
 	if s.pos.Char == 0 {
 		s.pos.Char = s.pos.Lines[len(s.pos.Lines)-1]
 		s.pos.Lines = s.pos.Lines[:len(s.pos.Lines)-1]
@@ -50,11 +93,14 @@ func (s *scanner) unread() {
 
 // Scan returns the next token and literal value.
 func (s *scanner) Scan() (tok token, lit string) {
+	s.unterminated = false
+	s.tooLong = false
 	ch := s.read()
 	if isWhitespace(ch) {
 		s.ignoreWhitespace()
 		ch = s.read()
 	}
+	s.start = s.pos
 	if isAlphanum(ch) {
 		s.unread()
 		return s.scanIdent()
@@ -67,23 +113,28 @@ func (s *scanner) Scan() (tok token, lit string) {
 	case ':':
 		return tCOLON, string(ch)
 	case ',':
-		parseField = false // reset parseField if reached end of field.
+		s.parseField = false // reset parseField if reached end of field.
 		return tCOMMA, string(ch)
 	case '=':
-		parseField = true // set parseField if = sign outside quoted or ident.
+		s.parseField = true // set parseField if = sign outside quoted or ident.
 		return tEQUAL, string(ch)
 	case '"':
 		return s.scanQuoted()
 	case '{':
-		if parseField {
+		if s.parseField {
 			return s.scanBraced()
 		}
 		return tLBRACE, string(ch)
 	case '}':
-		if parseField { // reset parseField if reached end of entry.
-			parseField = false
+		if s.parseField { // reset parseField if reached end of entry.
+			s.parseField = false
 		}
 		return tRBRACE, string(ch)
+	case '(':
+		return tLPAREN, string(ch)
+	case ')':
+		s.parseField = false // reset parseField if reached end of entry.
+		return tRPAREN, string(ch)
 	case '#':
 		return tPOUND, string(ch)
 	case ' ':
@@ -106,9 +157,11 @@ func (s *scanner) scanIdent() (tok token, lit string) {
 }
 
 func (s *scanner) scanBare() (token, string) {
-	var buf bytes.Buffer
-	var trailingWhitespace int
-	for {
+	buf := &s.buf
+	buf.Reset()
+	var trailingWhitespace int // Bytes of whitespace at the end of buf.
+	// Trailing whitespace is not part of the value, but still kept in buf.
+	for !s.overLimit(buf.Len()-trailingWhitespace) && !s.overLimit(trailingWhitespace) {
 		if ch := s.read(); ch == eof {
 			break
 		} else if !isAlphanum(ch) && !isBareSymbol(ch) && !isWhitespace(ch) {
@@ -116,7 +169,7 @@ func (s *scanner) scanBare() (token, string) {
 			break
 		} else {
 			if isWhitespace(ch) {
-				trailingWhitespace += 1
+				trailingWhitespace += utf8.RuneLen(ch)
 			} else {
 				trailingWhitespace = 0
 			}
@@ -125,13 +178,14 @@ func (s *scanner) scanBare() (token, string) {
 	}
 	buf.Truncate(buf.Len() - trailingWhitespace)
 	str := buf.String()
-	if strings.ToLower(str) == "comment" {
+	if strings.EqualFold(str, "comment") {
 		return tCOMMENT, str
-	} else if strings.ToLower(str) == "preamble" {
+	} else if strings.EqualFold(str, "preamble") {
 		return tPREAMBLE, str
-	} else if strings.ToLower(str) == "string" {
+	} else if strings.EqualFold(str, "string") {
 		return tSTRING, str
-	} else if _, err := strconv.Atoi(str); err == nil && parseField { // Special case for numeric
+	} else if isInteger(str) && s.parseField { // Special case for numeric
+		s.delim = DelimBare
 		return tIDENT, str
 	}
 	return tBAREIDENT, str
@@ -139,9 +193,10 @@ func (s *scanner) scanBare() (token, string) {
 
 // scanBraced parses a braced string, like {this}.
 func (s *scanner) scanBraced() (token, string) {
-	var buf bytes.Buffer
+	buf := &s.buf
+	buf.Reset()
 	brace := 1
-	for {
+	for !s.overLimit(buf.Len()) {
 		if ch := s.read(); ch == eof {
 			break
 		} else if ch == '\\' {
@@ -152,6 +207,7 @@ func (s *scanner) scanBraced() (token, string) {
 		} else if ch == '}' {
 			brace--
 			if brace == 0 { // Balances open brace.
+				s.delim = DelimBraces
 				return tIDENT, buf.String()
 			}
 			_, _ = buf.WriteRune(ch)
@@ -164,19 +220,46 @@ func (s *scanner) scanBraced() (token, string) {
 	return tILLEGAL, buf.String()
 }
 
-// scanQuoted parses a quoted string, like "this".
+// scanQuoted parses a quoted string, like "this". A " inside braces, e.g.
+// "a {"} b", is kept as it is and does not end the string.
+//
+// A quoted string that is still open at a line starting with @ or at EOF,
+// e.g. "this} mistyped, is unterminated. It is cut short at its first
+// unbalanced closing brace or line break, and the scanner stops before the @.
 func (s *scanner) scanQuoted() (token, string) {
-	var buf bytes.Buffer
+	buf := &s.buf
+	buf.Reset()
 	brace := 0
-	for {
-		if ch := s.read(); ch == eof {
+	cut := -1          // Where to cut the string short if unterminated.
+	lineStart := false // Whether only whitespace follows the last line break.
+	for !s.overLimit(buf.Len()) {
+		ch := s.read()
+		if ch == eof {
 			break
-		} else if ch == '{' {
+		} else if ch == '@' && lineStart {
+			s.unread()
+			break
+		}
+		if ch == '\n' {
+			if cut < 0 {
+				cut = buf.Len()
+			}
+			lineStart = true
+		} else if !isWhitespace(ch) {
+			lineStart = false
+		}
+		if ch == '{' {
+			_, _ = buf.WriteRune(ch)
 			brace++
 		} else if ch == '}' {
+			if brace == 0 && cut < 0 {
+				cut = buf.Len()
+			}
+			_, _ = buf.WriteRune(ch)
 			brace--
 		} else if ch == '"' {
 			if brace == 0 { // Matches open quote, unescaped
+				s.delim = DelimQuotes
 				return tIDENT, buf.String()
 			}
 			_, _ = buf.WriteRune(ch)
@@ -184,7 +267,34 @@ func (s *scanner) scanQuoted() (token, string) {
 			_, _ = buf.WriteRune(ch)
 		}
 	}
-	return tILLEGAL, buf.String()
+	if cut >= 0 {
+		buf.Truncate(cut)
+	}
+	s.delim = DelimQuotes
+	s.unterminated = true
+	return tIDENT, strings.TrimSpace(buf.String())
+}
+
+// overLimit returns true, and sets tooLong, if size, i.e. the size of the
+// value being scanned, is over maxValue.
+func (s *scanner) overLimit(size int) bool {
+	if s.maxValue > 0 && size > s.maxValue {
+		s.tooLong = true
+	}
+	return s.tooLong
+}
+
+// skipToEntry consumes everything up to the @ starting the next entry, or
+// EOF.
+func (s *scanner) skipToEntry() {
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if ch == '@' {
+			s.unread()
+			break
+		}
+	}
 }
 
 // ignoreWhitespace consumes the current rune and all contiguous whitespace.
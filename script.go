@@ -0,0 +1,41 @@
+package bibtex
+
+import "unicode"
+
+// scripts lists the scripts told apart by DetectScript, with their label.
+var scripts = []struct {
+	label  string
+	tables []*unicode.RangeTable
+}{
+	{"Latin", []*unicode.RangeTable{unicode.Latin}},
+	{"Cyrillic", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"CJK", []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul}},
+	{"Arabic", []*unicode.RangeTable{unicode.Arabic}},
+}
+
+// DetectScript returns a coarse label for the script most letters of s (e.g.
+// a title) are written in, after decoding LaTeX: "Latin", "Cyrillic", "CJK" or
+// "Arabic". Latin is only returned if there are no letters in another script,
+// since such titles often include Latin acronyms or a translation. It returns
+// "" if s has no letters in any of these scripts.
+func DetectScript(s string) string {
+	counts := make([]int, len(scripts))
+	for _, r := range decodeLaTeX(s, true) {
+		for i, script := range scripts {
+			if unicode.In(r, script.tables...) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	best := 0 // Latin.
+	for i, n := range counts[1:] {
+		if n > 0 && (best == 0 || n > counts[best]) {
+			best = i + 1
+		}
+	}
+	if counts[best] == 0 {
+		return ""
+	}
+	return scripts[best].label
+}
@@ -0,0 +1,19 @@
+package bibtex
+
+import "testing"
+
+func TestDetectScript(t *testing.T) {
+	for title, expected := range map[string]string{
+		`{\"U}ber die Vollst{\"a}ndigkeit`: "Latin",
+		"Война и мир":                      "Cyrillic",
+		"Война и мир (War and Peace)":      "Cyrillic",
+		"源氏物語":                             "CJK",
+		"ノルウェイの森":                          "CJK",
+		"ألف ليلة وليلة":                   "Arabic",
+		"2020":                             "",
+	} {
+		if want, got := expected, DetectScript(title); want != got {
+			t.Errorf("Expecting script %q for %q but got %q", want, title, got)
+		}
+	}
+}
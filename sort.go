@@ -0,0 +1,80 @@
+package bibtex
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortBibLaTeX sorts the entries of bib by name, year and title following the
+// BibLaTeX nyt sorting scheme.
+//
+// The presort field (default "mm") is compared first. If an entry has a
+// sortkey field, it is used in place of the name and no further fields are
+// compared. Otherwise the sortname/author/editor, sortyear/year and
+// sorttitle/title fields are used in turn, names by family name (with any
+// von part) and then given names. Values are compared with LaTeX decoded,
+// braces removed, accents stripped and case folded. Entries that compare
+// equal keep their original order.
+func (bib *BibTex) SortBibLaTeX() {
+	keys := make(map[*BibEntry][]string, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		keys[entry] = entry.bibLaTeXSortKey()
+	}
+	sort.SliceStable(bib.Entries, func(i, j int) bool {
+		ki, kj := keys[bib.Entries[i]], keys[bib.Entries[j]]
+		for n := range ki {
+			if ki[n] != kj[n] {
+				return ki[n] < kj[n]
+			}
+		}
+		return false
+	})
+}
+
+// bibLaTeXSortKey returns the list of (folded) values to compare entry by.
+func (entry *BibEntry) bibLaTeXSortKey() []string {
+	presort := entry.firstField("presort")
+	if presort == "" {
+		presort = "mm"
+	}
+	if sortkey := entry.firstField("sortkey"); sortkey != "" {
+		return []string{sortValue(presort), sortValue(sortkey), "", ""}
+	}
+	return []string{
+		sortValue(presort),
+		sortNames(entry.firstField("sortname", "author", "editor")),
+		sortValue(entry.firstField("sortyear", "year")),
+		sortValue(entry.firstField("sorttitle", "title")),
+	}
+}
+
+// sortNames returns the sort key of a list of names: the von part and family
+// name of each name followed by its given names and suffix, see sortValue.
+func sortNames(s string) string {
+	var parts []string
+	for _, n := range ParseNames(s).Names {
+		parts = append(parts, sortValue(n.Von+" "+n.Last), sortValue(n.First), sortValue(n.Jr))
+	}
+	if parts == nil {
+		return sortValue(s)
+	}
+	return strings.Join(parts, "\x00") // Sorts before any character.
+}
+
+// sortValue returns s as compared for sorting: LaTeX decoded, braces removed,
+// accents stripped and case folded.
+func sortValue(s string) string {
+	return foldKey(strings.TrimSpace(stripAccents(decodeLaTeX(s, true))))
+}
+
+// firstField returns the value of the first of the given fields that is set.
+func (entry *BibEntry) firstField(names ...string) string {
+	for _, name := range names {
+		if val, ok := entry.field(name); ok {
+			if s := strings.TrimSpace(val.String()); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package bibtex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortBibLaTeX(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{smith2020, author = {Smith, John}, year = 2020, title = {B}}
+@article{doe, author = {Doe, Jane}, year = 2018, title = {Z}}
+@article{smith2019, author = {Smith, John}, year = 2019, title = {A}}
+@misc{manual, title = {Manual}, sortkey = {Adams}}
+@misc{first, author = {Zed, Zoe}, presort = {aa}}
+@article{smith2020a, author = {Smith, John}, year = 2020, title = {A}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib.SortBibLaTeX()
+	expected := []string{"first", "manual", "doe", "smith2019", "smith2020a", "smith2020"}
+	if want, got := len(expected), len(bib.Entries); want != got {
+		t.Fatalf("Expecting %d entries but got %d", want, got)
+	}
+	for i, entry := range bib.Entries {
+		if want, got := expected[i], entry.CiteName; want != got {
+			t.Errorf("Expecting entry %d to be %s but got %s", i, want, got)
+		}
+	}
+}
+
+// Tests that names are sorted by family name and values with LaTeX decoded.
+func TestSortBibLaTeXNames(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@book{zed, author = {John Zed}}
+@book{young, author = {Young, Yara}}
+@book{ozturk, author = {{\"O}zt{\"u}rk, Ali}}
+@book{brown, author = {Brown, Bob}}
+@book{vonb, author = {Ludwig van Beethoven}}
+@book{smithb, author = {Smith, Bea}, title = {\emph{Zebras}}}
+@book{smitha, author = {Smith, Bea}, title = {{\"U}ber}}
+@book{smithann, author = {Ann Smith}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib.SortBibLaTeX()
+	expected := []string{"brown", "ozturk", "smithann", "smitha", "smithb", "vonb", "young", "zed"}
+	for i, entry := range bib.Entries {
+		if want, got := expected[i], entry.CiteName; want != got {
+			t.Errorf("Expecting entry %d to be %s but got %s", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package bibtex
+
+import "strings"
+
+// NewEntry returns an entry of the given type, with an empty value for each
+// field required by the type so that callers can fill them in. Where one of
+// several fields is required (e.g. year or date), the first is added.
+func NewEntry(entryType, citeName string) *BibEntry {
+	entry := NewBibEntry(entryType, citeName)
+	for _, alternatives := range requiredFields[entry.Type] {
+		entry.AddField(alternatives[0], NewBibConst(""))
+	}
+	return entry
+}
+
+// NewArticle returns an @article with its required fields, see NewEntry.
+func NewArticle(citeName string) *BibEntry {
+	return NewEntry("article", citeName)
+}
+
+// NewBook returns a @book with its required fields, see NewEntry.
+func NewBook(citeName string) *BibEntry {
+	return NewEntry("book", citeName)
+}
+
+// NewInProceedings returns an @inproceedings with its required fields, see
+// NewEntry.
+func NewInProceedings(citeName string) *BibEntry {
+	return NewEntry("inproceedings", citeName)
+}
+
+// SetAuthors sets the author field of entry (whatever the case of its name)
+// to names, in "von Last, Jr, First" form separated by "and".
+func (entry *BibEntry) SetAuthors(names []Name) {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name.String()
+	}
+	author, ok := entry.fieldName("author")
+	if !ok {
+		author = "author"
+	}
+	entry.AddField(author, NewBibConst(strings.Join(parts, " and ")))
+}
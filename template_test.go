@@ -0,0 +1,65 @@
+package bibtex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewArticle(t *testing.T) {
+	entry := NewArticle("smith2020")
+	for _, field := range []string{"author", "title", "journal", "year"} {
+		if val, ok := entry.Fields[field]; !ok || val.String() != "" {
+			t.Errorf("Expecting empty %s field but got %v", field, val)
+		}
+	}
+	if want, got := 4, len(entry.Fields); want != got {
+		t.Errorf("Expecting %d fields but got %d: %v", want, got, entry.Fields)
+	}
+
+	entry.SetAuthors([]Name{
+		{First: "John", Last: "Smith"},
+		{First: "Ludwig", Von: "van", Last: "Beethoven"},
+	})
+	entry.AddField("title", NewBibConst("A Title"))
+	entry.AddField("journal", NewBibConst("Journal"))
+	entry.AddField("year", NewBibConst("2020"))
+	if err := entry.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	bib := NewBibTex()
+	bib.AddEntry(entry)
+	expected := `@article{smith2020,
+    author  = "Smith, John and van Beethoven, Ludwig",
+    title   = "A Title",
+    journal = "Journal",
+    year    = 2020
+}
+`
+	if s := bib.PrettyString(); s != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", s, expected)
+	}
+	bib2, err := Parse(strings.NewReader(bib.PrettyString()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := ParseNames(bib2.Entries[0].Fields["author"].String()).Names
+	if want, got := (Name{First: "Ludwig", Von: "van", Last: "Beethoven"}), names[1]; want != got {
+		t.Errorf("Expecting author %#v but got %#v", want, got)
+	}
+}
+
+// Tests that SetAuthors replaces an author field of any case.
+func TestSetAuthorsCase(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{a, Author = {Doe, Jane}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := bib.Entries[0]
+	entry.SetAuthors([]Name{{First: "John", Last: "Smith"}})
+	expected := []Pair{{"Author", "Smith, John"}}
+	if got := entry.Pairs(false); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting fields %v but got %v", expected, got)
+	}
+}
@@ -25,8 +25,23 @@ func (p tokenPos) String() string {
 	return fmt.Sprintf("%d:%d", len(p.Lines)+1, p.Char)
 }
 
+// before returns true if p is before q in the input.
+func (p tokenPos) before(q tokenPos) bool {
+	if len(p.Lines) != len(q.Lines) {
+		return len(p.Lines) < len(q.Lines)
+	}
+	return p.Char < q.Char
+}
+
+// isWhitespace returns true if ch separates tokens: a space, tab, line break,
+// vertical tab, form feed or no-break space. Whitespace in braced and quoted
+// values is kept as it is.
 func isWhitespace(ch rune) bool {
-	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+	switch ch {
+	case ' ', '\t', '\n', '\r', '\v', '\f', '\u00a0':
+		return true
+	}
+	return false
 }
 
 func isAlpha(ch rune) bool {
@@ -37,6 +52,22 @@ func isDigit(ch rune) bool {
 	return ('0' <= ch && ch <= '9')
 }
 
+// isInteger returns true if s is an optionally signed decimal integer.
+func isInteger(s string) bool {
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if !isDigit(ch) {
+			return false
+		}
+	}
+	return true
+}
+
 func isAlphanum(ch rune) bool {
 	return isAlpha(ch) || isDigit(ch)
 }
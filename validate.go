@@ -0,0 +1,210 @@
+package bibtex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// requiredFields lists the fields required by each standard entry type. Each
+// requirement is a list of alternatives, at least one of which must be set.
+var requiredFields = map[string][][]string{
+	"article":       {{"author"}, {"title"}, {"journal"}, {"year", "date"}},
+	"book":          {{"author", "editor"}, {"title"}, {"publisher"}, {"year", "date"}},
+	"booklet":       {{"title"}},
+	"inbook":        {{"author", "editor"}, {"title"}, {"chapter", "pages"}, {"publisher"}, {"year", "date"}},
+	"incollection":  {{"author"}, {"title"}, {"booktitle"}, {"publisher"}, {"year", "date"}},
+	"inproceedings": {{"author"}, {"title"}, {"booktitle"}, {"year", "date"}},
+	"conference":    {{"author"}, {"title"}, {"booktitle"}, {"year", "date"}},
+	"manual":        {{"title"}},
+	"mastersthesis": {{"author"}, {"title"}, {"school"}, {"year", "date"}},
+	"phdthesis":     {{"author"}, {"title"}, {"school"}, {"year", "date"}},
+	"proceedings":   {{"title"}, {"year", "date"}},
+	"techreport":    {{"author"}, {"title"}, {"institution"}, {"year", "date"}},
+	"unpublished":   {{"author"}, {"title"}, {"note"}},
+}
+
+// Validate checks that entry has the fields required by its type, returning
+// an error listing the missing ones. Entries of non-standard types are
+// always valid.
+func (entry *BibEntry) Validate() error {
+	fields := make(map[string]bool, len(entry.Fields))
+	for name := range entry.Fields {
+		fields[foldKey(name)] = true
+	}
+
+	var missing []string
+	for _, alternatives := range requiredFields[foldKey(entry.Type)] {
+		found := false
+		for _, name := range alternatives {
+			found = found || fields[name]
+		}
+		if !found {
+			missing = append(missing, strings.Join(alternatives, " or "))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: %w: %s", entry.CiteName, ErrMissingField, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CheckKey checks that the cite name of entry is not empty and only made of
+// ASCII letters, digits and the symbols - _ : . / +, so that it is read back
+// as it is by the parser.
+func (entry *BibEntry) CheckKey() error {
+	if entry.CiteName == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidKey)
+	}
+	for _, ch := range entry.CiteName {
+		if !isAlphanum(ch) && !isBareSymbol(ch) {
+			return fmt.Errorf("%s: %w: %q is not allowed", entry.CiteName, ErrInvalidKey, ch)
+		}
+	}
+	return nil
+}
+
+// ValidateKeys checks the cite names of all entries of bib, returning every
+// malformed (see BibEntry.CheckKey) and duplicate (case-insensitively) cite
+// name, in entry order. The cite names of @modify entries are not duplicates.
+func (bib *BibTex) ValidateKeys() []error {
+	var errs []error
+	seen := make(map[string]bool, len(bib.Entries))
+	for _, entry := range bib.Entries {
+		if err := entry.CheckKey(); err != nil {
+			errs = append(errs, err)
+		}
+		if isModify(entry) {
+			continue // Refers to another entry, see ApplyModifies.
+		}
+		key := foldKey(entry.CiteName)
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrDuplicateKey, entry.CiteName))
+		}
+		seen[key] = true
+	}
+	return errs
+}
+
+// identifierPrefix matches the label an ISBN or ISSN may be written with.
+var identifierPrefix = regexp.MustCompile(`^(?i:isbn|issn)(?:-1[03])?:?\s*`)
+
+// identifierDigits returns the digits of an ISBN or ISSN, without its label,
+// hyphens and spaces, and with x made uppercase. It returns false if there
+// is any other character, or an X other than last.
+func identifierDigits(s string) (string, bool) {
+	s = identifierPrefix.ReplaceAllString(strings.TrimSpace(s), "")
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+	for i, ch := range digits {
+		if !isDigit(ch) && (ch != 'X' || i != len(digits)-1) {
+			return digits, false
+		}
+	}
+	return digits, digits != ""
+}
+
+// mod11Valid returns true if the weighted sum of digits, with weights from
+// len(digits) down to 1 and X for 10, is a multiple of 11. This is the check
+// for ISBN-10 and ISSN.
+func mod11Valid(digits string) bool {
+	sum := 0
+	for i, ch := range digits {
+		d := int(ch - '0')
+		if ch == 'X' {
+			d = 10
+		}
+		sum += (len(digits) - i) * d
+	}
+	return sum%11 == 0
+}
+
+// isbn13Valid returns true if the ISBN-13 digits, weighted alternately by 1
+// and 3, sum to a multiple of 10.
+func isbn13Valid(digits string) bool {
+	sum := 0
+	for i, ch := range digits {
+		if ch == 'X' {
+			return false
+		}
+		sum += int(ch-'0') * (1 + 2*(i%2))
+	}
+	return sum%10 == 0
+}
+
+// CheckISBN checks the isbn field of entry, an ISBN-10 or ISBN-13 optionally
+// with hyphens or spaces, returning an error if it is malformed or has a wrong
+// check digit. Entries without isbn are valid.
+func (entry *BibEntry) CheckISBN() error {
+	value := entry.firstField("isbn")
+	if value == "" {
+		return nil
+	}
+	digits, ok := identifierDigits(value)
+	switch {
+	case !ok:
+		return fmt.Errorf("%s: %w: %q is not made of digits", entry.CiteName, ErrInvalidISBN, value)
+	case len(digits) != 10 && len(digits) != 13:
+		return fmt.Errorf("%s: %w: %q has %d digits, expecting 10 or 13", entry.CiteName, ErrInvalidISBN, value, len(digits))
+	case len(digits) == 10 && !mod11Valid(digits), len(digits) == 13 && !isbn13Valid(digits):
+		return fmt.Errorf("%s: %w: %q has a wrong check digit", entry.CiteName, ErrInvalidISBN, value)
+	}
+	return nil
+}
+
+// CheckISSN checks the issn field of entry, optionally with a hyphen,
+// returning an error if it is malformed or has a wrong check digit. Entries
+// without issn are valid.
+func (entry *BibEntry) CheckISSN() error {
+	value := entry.firstField("issn")
+	if value == "" {
+		return nil
+	}
+	digits, ok := identifierDigits(value)
+	switch {
+	case !ok:
+		return fmt.Errorf("%s: %w: %q is not made of digits", entry.CiteName, ErrInvalidISSN, value)
+	case len(digits) != 8:
+		return fmt.Errorf("%s: %w: %q has %d digits, expecting 8", entry.CiteName, ErrInvalidISSN, value, len(digits))
+	case !mod11Valid(digits):
+		return fmt.Errorf("%s: %w: %q has a wrong check digit", entry.CiteName, ErrInvalidISSN, value)
+	}
+	return nil
+}
+
+// UnresolvedString is a reference to an undefined string var.
+type UnresolvedString struct {
+	CiteName string // Entry the reference is in.
+	Field    string // Field the reference is in.
+	Macro    string // Name of the string var.
+}
+
+// UnresolvedStrings returns the references to string vars that were not
+// defined when the entry was parsed, which a lenient Parser keeps as bare
+// words (see Parser.Strict). References are listed in entry and field order.
+// Values are not changed.
+func (bib *BibTex) UnresolvedStrings() []UnresolvedString {
+	var refs []UnresolvedString
+	for _, entry := range bib.Entries {
+		for _, name := range entry.fieldNames() {
+			for _, v := range stringVarRefs(entry.Fields[name], nil) {
+				if v.undefined {
+					refs = append(refs, UnresolvedString{CiteName: entry.CiteName, Field: name, Macro: v.Key})
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// stringVarRefs appends the string vars referenced by s to refs.
+func stringVarRefs(s BibString, refs []*BibVar) []*BibVar {
+	switch s := s.(type) {
+	case *BibVar:
+		refs = append(refs, s)
+	case *BibComposite:
+		for _, part := range *s {
+			refs = stringVarRefs(part, refs)
+		}
+	}
+	return refs
+}
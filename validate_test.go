@@ -0,0 +1,178 @@
+package bibtex
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@book{edited,
+  editor = {Doe, Jane},
+  title = {Collected Works},
+  publisher = {Publisher},
+  date = {2020-01},
+}
+@book{anonymous,
+  title = {Collected Works},
+  publisher = {Publisher},
+  year = 2020,
+}
+@article{complete,
+  author = {Doe, John},
+  title = {Title},
+  journal = {Journal},
+  year = 2020,
+}
+@misc{anything, note = {Nothing is required}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if err := bib.Entries[i].Validate(); err != nil {
+			t.Errorf("Expecting %s to be valid but got: %v", bib.Entries[i].CiteName, err)
+		}
+	}
+
+	err = bib.Entries[1].Validate()
+	if !errors.Is(err, ErrMissingField) {
+		t.Fatalf("Expecting missing field error but got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "author or editor") {
+		t.Errorf("Expecting error to name the alternatives but got: %v", err)
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@article{Smith:2020, title = {A}}
+@article{doe-1999, title = {B}}
+@misc{smith:2020, title = {C}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bib.AddEntry(NewBibEntry("misc", "bad{key}"))
+	errs := bib.ValidateKeys()
+	if want, got := 2, len(errs); want != got {
+		t.Fatalf("Expecting %d errors but got %d: %v", want, got, errs)
+	}
+	if !errors.Is(errs[0], ErrDuplicateKey) || !strings.Contains(errs[0].Error(), "smith:2020") {
+		t.Errorf("Expecting duplicate smith:2020 but got: %v", errs[0])
+	}
+	if !errors.Is(errs[1], ErrInvalidKey) || !strings.Contains(errs[1].Error(), "bad{key}") {
+		t.Errorf("Expecting invalid bad{key} but got: %v", errs[1])
+	}
+	if err := NewBibEntry("misc", "").CheckKey(); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expecting empty key to be invalid but got: %v", err)
+	}
+}
+
+func TestCheckISBN(t *testing.T) {
+	for isbn, expected := range map[string]string{
+		"978-0-306-40615-7":      "",
+		"ISBN 978 0 306 40615 7": "",
+		"0-8044-2957-x":          "",
+		"978-0-306-40615-8":      "wrong check digit",
+		"0-306-40615-3":          "wrong check digit",
+		"978-0-306-4061":         "has 11 digits",
+		"978-0-306-4061X-7":      "not made of digits",
+	} {
+		entry := NewBibEntry("book", "a")
+		entry.AddField("isbn", NewBibConst(isbn))
+		err := entry.CheckISBN()
+		if expected == "" {
+			if err != nil {
+				t.Errorf("Expecting %q to be valid but got: %v", isbn, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidISBN) || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expecting %q to be invalid (%s) but got: %v", isbn, expected, err)
+		}
+	}
+	if err := NewBibEntry("book", "a").CheckISBN(); err != nil {
+		t.Errorf("Expecting no isbn to be valid but got: %v", err)
+	}
+}
+
+func TestCheckISSN(t *testing.T) {
+	for issn, expected := range map[string]string{
+		"0378-5955":  "",
+		"2434-561X":  "",
+		"0378-5956":  "wrong check digit",
+		"0378-595":   "has 7 digits",
+		"03X78-5955": "not made of digits",
+	} {
+		entry := NewBibEntry("article", "a")
+		entry.AddField("issn", NewBibConst(issn))
+		err := entry.CheckISSN()
+		if expected == "" {
+			if err != nil {
+				t.Errorf("Expecting %q to be valid but got: %v", issn, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidISSN) || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expecting %q to be invalid (%s) but got: %v", issn, expected, err)
+		}
+	}
+}
+
+func TestUnresolvedStrings(t *testing.T) {
+	p := new(Parser)
+	bib, err := p.Parse(strings.NewReader(`@string{acm = {ACM}}
+@article{a, publisher = acm, journal = {Comm. } # cacm, month = jan}
+@article{b, note = later}
+@string{later = {Later}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []UnresolvedString{
+		{CiteName: "a", Field: "journal", Macro: "cacm"},
+		{CiteName: "b", Field: "note", Macro: "later"},
+	}
+	if got := bib.UnresolvedStrings(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting unresolved strings %v but got %v", expected, got)
+	}
+	if want, got := "Comm. cacm", bib.Entries[0].Fields["journal"].String(); want != got {
+		t.Errorf("Expecting journal %q to be unchanged but got %q", want, got)
+	}
+}
+
+// Tests that references to string vars defined more than once, in one file or
+// in merged files, are resolved.
+func TestUnresolvedStringsRedefined(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{x = {X}}
+@article{t, title = x}
+@string{x = {Y}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bib.UnresolvedStrings(); len(got) != 0 {
+		t.Errorf("Expecting no unresolved strings after a redefinition but got %v", got)
+	}
+
+	bib, err = Parse(strings.NewReader(`@string{j = {J. One}}
+@article{x, journal = j}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Parse(strings.NewReader(`@string{j = {J. Two}}
+@article{y, journal = j, note = missing}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bib.Merge(other, ConflictKeepFirst); err != nil {
+		t.Fatal(err)
+	}
+	expected := []UnresolvedString{{CiteName: "y", Field: "note", Macro: "missing"}}
+	if got := bib.UnresolvedStrings(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expecting unresolved strings %v after a merge but got %v", expected, got)
+	}
+}
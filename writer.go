@@ -0,0 +1,65 @@
+package bibtex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// EntryWriter writes BibTeX entries, string vars and preambles to an
+// underlying writer one at a time, as they are produced. Entries are laid out
+// by a Formatter.
+type EntryWriter struct {
+	w   io.Writer
+	f   *Formatter
+	buf bytes.Buffer
+	n   int // Number of items written.
+}
+
+// NewEntryWriter returns an EntryWriter writing to w, formatting entries with
+// f (or the default Formatter if f is nil).
+func NewEntryWriter(w io.Writer, f *Formatter) *EntryWriter {
+	if f == nil {
+		f = NewFormatter()
+	}
+	return &EntryWriter{w: w, f: f}
+}
+
+// WriteEntry writes a BibTeX entry.
+func (w *EntryWriter) WriteEntry(entry *BibEntry) error {
+	w.begin()
+	w.f.writeEntry(&w.buf, entry)
+	return w.flush()
+}
+
+// WriteString writes a string var definition.
+func (w *EntryWriter) WriteString(key string, val BibString) error {
+	w.begin()
+	fmt.Fprintf(&w.buf, "@string{%s = %s}\n", key, val.RawString())
+	return w.flush()
+}
+
+// WritePreamble writes a preamble.
+func (w *EntryWriter) WritePreamble(p BibString) error {
+	w.begin()
+	fmt.Fprintf(&w.buf, "@preamble{%s}\n", p.RawString())
+	return w.flush()
+}
+
+// begin starts writing a new item, separated from the previous one as
+// configured by the Formatter.
+func (w *EntryWriter) begin() {
+	w.buf.Reset()
+	if w.n > 0 && w.f.BlankLineBetweenEntries {
+		w.buf.WriteString("\n")
+	}
+}
+
+// flush writes the buffered item to the underlying writer.
+func (w *EntryWriter) flush() error {
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	w.n++
+	return nil
+}
@@ -0,0 +1,57 @@
+package bibtex
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEntryWriter(t *testing.T) {
+	bib, err := Parse(strings.NewReader(`@string{pub = {Publisher}}
+@article{a, title = {Kept}, year = 2020}
+@book{b, title = {Dropped}, publisher = pub}
+@article{c, title = {Also kept}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := NewEntryWriter(&buf, nil)
+	if err := w.WriteString("pub", bib.GetStringVar("pub").Value); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range bib.Entries {
+		if entry.Type != "article" {
+			continue
+		}
+		entry.AddField("note", NewBibConst("Filtered"))
+		if err := w.WriteEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `@string{pub = {Publisher}}
+
+@article{a,
+    title = "Kept",
+    year  = 2020,
+    note  = "Filtered"
+}
+
+@article{c,
+    title = "Also kept",
+    note  = "Filtered"
+}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("Output does not match, got:\n%s\nexpected:\n%s", got, expected)
+	}
+	bib2, err := Parse(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(bib2.Entries); want != got {
+		t.Errorf("Expecting %d entries but got %d", want, got)
+	}
+}